@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// aurArchiveName is the name of the AUR metadata archive, both on the AUR and
+// in the cache.
+const aurArchiveName = "packages-meta-ext-v1.json.gz"
+
+// aurArchiveHeaders is saved next to the archive to make the next download
+// conditional.
+type aurArchiveHeaders struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+	Checked      int64  `json:"checked"`
+}
+
+// aurArchive indexes the metadata of every AUR package by name and by the
+// names the packages provide.
+type aurArchive struct {
+	pkgs     map[string]*rpc.Pkg
+	provides map[string][]string
+	names    []string
+}
+
+// aurArchiveState holds the archive, loaded on first use.
+var aurArchiveState struct {
+	once    sync.Once
+	archive *aurArchive
+}
+
+func readArchiveHeaders(file string) aurArchiveHeaders {
+	var headers aurArchiveHeaders
+	if data, err := ioutil.ReadFile(file + ".headers"); err == nil {
+		_ = json.Unmarshal(data, &headers)
+	}
+
+	return headers
+}
+
+// fetchAURArchive downloads the archive from the AUR to file unless the copy
+// in file is still current. It returns whether a new archive was downloaded.
+func fetchAURArchive(file string) (bool, error) {
+	headers := readArchiveHeaders(file)
+
+	req, err := http.NewRequest("GET", config.AURURL+"/"+aurArchiveName, nil)
+	if err != nil {
+		return false, err
+	}
+	// The archive is served with Content-Encoding: gzip, asking for it keeps
+	// the transport from decompressing it so it is saved as is
+	req.Header.Set("Accept-Encoding", "gzip")
+	if _, err = os.Stat(file); err == nil {
+		if headers.ETag != "" {
+			req.Header.Set("If-None-Match", headers.ETag)
+		}
+		if headers.LastModified != "" {
+			req.Header.Set("If-Modified-Since", headers.LastModified)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	downloaded := false
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+	case http.StatusOK:
+		tmp := file + ".part"
+		out, err := os.Create(tmp)
+		if err != nil {
+			return false, err
+		}
+		_, err = io.Copy(out, resp.Body)
+		out.Close()
+		if err != nil {
+			os.Remove(tmp)
+			return false, err
+		}
+		if err = os.Rename(tmp, file); err != nil {
+			return false, err
+		}
+
+		headers.ETag = resp.Header.Get("ETag")
+		headers.LastModified = resp.Header.Get("Last-Modified")
+		downloaded = true
+	default:
+		return false, fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+
+	headers.Checked = time.Now().Unix()
+	marshalled, err := json.Marshal(headers)
+	if err != nil {
+		return downloaded, err
+	}
+
+	return downloaded, writeFileSync(file+".headers", marshalled)
+}
+
+// readAURArchive reads and indexes the archive saved in file. An archive that
+// was saved decompressed is read as is.
+func readAURArchive(file string) (*aurArchive, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	buffered := bufio.NewReader(in)
+	var data io.Reader = buffered
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %s", file, err)
+		}
+		defer gz.Close()
+		data = gz
+	}
+
+	var pkgs []*rpc.Pkg
+	if err = json.NewDecoder(data).Decode(&pkgs); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", file, err)
+	}
+
+	archive := &aurArchive{
+		make(map[string]*rpc.Pkg, len(pkgs)),
+		make(map[string][]string),
+		make([]string, 0, len(pkgs)),
+	}
+
+	for _, pkg := range pkgs {
+		archive.pkgs[pkg.Name] = pkg
+		archive.names = append(archive.names, pkg.Name)
+		for _, provide := range pkg.Provides {
+			name, _, _ := splitDep(provide)
+			archive.provides[name] = append(archive.provides[name], pkg.Name)
+		}
+	}
+	sort.Strings(archive.names)
+
+	return archive, nil
+}
+
+// loadAURArchive returns the metadata archive when --aurmetadata is set,
+// downloading it again once the copy in the cache is older than the TTL. It
+// returns nil when the archive can not be used, the RPC is used instead.
+func loadAURArchive() *aurArchive {
+	if !config.AURMetadata {
+		return nil
+	}
+
+	aurArchiveState.once.Do(func() {
+		file := filepath.Join(cacheHome, aurArchiveName)
+		checked := time.Unix(readArchiveHeaders(file).Checked, 0)
+
+		if !config.Offline && time.Since(checked) >= time.Duration(config.AURCacheTTL)*time.Minute {
+			if _, err := fetchAURArchive(file); err != nil {
+				fmt.Fprintln(os.Stderr, bold(yellow(arrow+" Warning: "))+"Failed to update the AUR metadata:", err)
+			}
+		}
+
+		archive, err := readAURArchive(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fmt.Fprintln(os.Stderr, bold(yellow(arrow+" Warning: "))+err.Error())
+			}
+			return
+		}
+
+		aurArchiveState.archive = archive
+	})
+
+	return aurArchiveState.archive
+}
+
+// lookup returns the packages of names found in the archive.
+func (archive *aurArchive) lookup(names []string) []*rpc.Pkg {
+	pkgs := make([]*rpc.Pkg, 0, len(names))
+	for _, name := range names {
+		if pkg, ok := archive.pkgs[name]; ok {
+			copied := *pkg
+			pkgs = append(pkgs, &copied)
+		}
+	}
+
+	return pkgs
+}
+
+// providers returns the names of the packages providing dep, by name or by
+// a provide.
+func (archive *aurArchive) providers(dep string) []string {
+	name, _, _ := splitDep(dep)
+	providers := make([]string, 0, len(archive.provides[name])+1)
+	if _, ok := archive.pkgs[name]; ok {
+		providers = append(providers, name)
+	}
+
+	return append(providers, archive.provides[name]...)
+}
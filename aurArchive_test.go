@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testArchive = `[
+	{"Name": "yay", "PackageBase": "yay", "Version": "9.2.1-1", "Maintainer": "jguer", "Depends": ["pacman>=5.1", "sudo"]},
+	{"Name": "yay-bin", "PackageBase": "yay-bin", "Version": "9.2.1-1", "Provides": ["yay=9.2.1"], "Conflicts": ["yay"]},
+	{"Name": "yay-git", "PackageBase": "yay-git", "Version": "9.2.1.r0-1", "Provides": ["yay"]}
+]`
+
+func TestFetchAURArchive(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(testArchive))
+	w.Close()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/"+aurArchiveName {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(gz.Bytes())
+	}))
+	defer server.Close()
+
+	old := config
+	config = defaultSettings()
+	config.AURURL = server.URL
+	defer func() { config = old }()
+
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, aurArchiveName)
+
+	downloaded, err := fetchAURArchive(file)
+	if err != nil || !downloaded {
+		t.Fatalf("expected the archive to be downloaded got %t, %v", downloaded, err)
+	}
+
+	downloaded, err = fetchAURArchive(file)
+	if err != nil || downloaded {
+		t.Fatalf("expected the archive to not be modified got %t, %v", downloaded, err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests got %d", requests)
+	}
+
+	archive, err := readAURArchive(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(archive.names, []string{"yay", "yay-bin", "yay-git"}) {
+		t.Errorf("expected names [yay yay-bin yay-git] got %v", archive.names)
+	}
+
+	pkgs := archive.lookup([]string{"yay", "missing"})
+	if len(pkgs) != 1 || pkgs[0].Version != "9.2.1-1" || len(pkgs[0].Depends) != 2 {
+		t.Errorf("expected yay 9.2.1-1 with 2 depends got %v", pkgs)
+	}
+
+	providers := archive.providers("yay>=9")
+	if !reflect.DeepEqual(providers, []string{"yay", "yay-bin", "yay-git"}) {
+		t.Errorf("expected providers [yay yay-bin yay-git] got %v", providers)
+	}
+
+	config.AURURL = server.URL + "/missing"
+	if _, err = fetchAURArchive(file); err == nil {
+		t.Errorf("expected an error for a missing archive")
+	}
+}
+
+func TestFetchAURArchiveContentEncoding(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(testArchive))
+	w.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gz.Bytes())
+	}))
+	defer server.Close()
+
+	old := config
+	config = defaultSettings()
+	config.AURURL = server.URL
+	defer func() { config = old }()
+
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, aurArchiveName)
+
+	if _, err = fetchAURArchive(file); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := readAURArchive(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.names) != 3 {
+		t.Errorf("expected 3 packages got %v", archive.names)
+	}
+
+	// An archive an older version saved decompressed is still read
+	if err = ioutil.WriteFile(file, []byte(testArchive), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if archive, err = readAURArchive(file); err != nil || len(archive.names) != 3 {
+		t.Errorf("expected the plain archive to be read got %v", err)
+	}
+}
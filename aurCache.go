@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// aurCacheEntry is a package as the RPC last returned it.
+type aurCacheEntry struct {
+	Pkg     rpc.Pkg `json:"pkg"`
+	Fetched int64   `json:"fetched"`
+}
+
+// aurCache holds the packages returned by info requests to the RPC, keyed by
+// name. Entries older than ttl are refreshed when online and reported as
+// stale when they have to be used anyway.
+type aurCache struct {
+	sync.Mutex
+	pkgs map[string]*aurCacheEntry
+	ttl  time.Duration
+	// usedStale are the packages stale entries were used for
+	usedStale stringSet
+}
+
+// aurMetadata is the cache saved to aurCacheFile, loaded on first use.
+var aurMetadata struct {
+	once  sync.Once
+	cache *aurCache
+}
+
+func makeAURCache(ttl time.Duration) *aurCache {
+	return &aurCache{pkgs: make(map[string]*aurCacheEntry), ttl: ttl, usedStale: make(stringSet)}
+}
+
+// loadAURCache returns the cache, reading it from disk the first time. A
+// cache that can not be read is started over.
+func loadAURCache() *aurCache {
+	aurMetadata.once.Do(func() {
+		cache := makeAURCache(time.Duration(config.AURCacheTTL) * time.Minute)
+
+		data, err := ioutil.ReadFile(aurCacheFile)
+		if err == nil {
+			if err = json.Unmarshal(data, &cache.pkgs); err != nil || cache.pkgs == nil {
+				cache.pkgs = make(map[string]*aurCacheEntry)
+			}
+		} else if !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "Failed to read AUR cache:", err)
+		}
+
+		aurMetadata.cache = cache
+	})
+
+	return aurMetadata.cache
+}
+
+func (cache *aurCache) save() error {
+	cache.Lock()
+	marshalled, err := json.Marshal(cache.pkgs)
+	cache.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a half written cache
+	tmp := aurCacheFile + ".tmp"
+	if err = writeFileSync(tmp, marshalled); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, aurCacheFile)
+}
+
+func (cache *aurCache) stale(entry *aurCacheEntry, now time.Time) bool {
+	return now.Sub(time.Unix(entry.Fetched, 0)) >= cache.ttl
+}
+
+// lookup splits names into the packages cached within the ttl, the ones
+// cached longer ago and the names that are not cached.
+func (cache *aurCache) lookup(names []string, now time.Time) ([]*rpc.Pkg, []*rpc.Pkg, []string) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	fresh := make([]*rpc.Pkg, 0, len(names))
+	stale := make([]*rpc.Pkg, 0)
+	uncached := make([]string, 0)
+
+	for _, name := range names {
+		entry, ok := cache.pkgs[name]
+		if !ok {
+			uncached = append(uncached, name)
+			continue
+		}
+
+		pkg := entry.Pkg
+		if cache.stale(entry, now) {
+			stale = append(stale, &pkg)
+		} else {
+			fresh = append(fresh, &pkg)
+		}
+	}
+
+	return fresh, stale, uncached
+}
+
+// update stores the packages the RPC returned for the names queried. Queried
+// names the RPC did not return no longer exist and are forgotten.
+func (cache *aurCache) update(queried []string, pkgs []*rpc.Pkg, now time.Time) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for _, name := range queried {
+		delete(cache.pkgs, name)
+	}
+	for _, pkg := range pkgs {
+		cache.pkgs[pkg.Name] = &aurCacheEntry{*pkg, now.Unix()}
+	}
+}
+
+// useStale records that the stale entries of pkgs are used.
+func (cache *aurCache) useStale(pkgs []*rpc.Pkg) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for _, pkg := range pkgs {
+		cache.usedStale.set(pkg.Name)
+	}
+}
+
+// staleSince returns when name was fetched if its stale entry is used.
+func (cache *aurCache) staleSince(name string) (time.Time, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry, ok := cache.pkgs[name]
+	if !ok || !cache.usedStale.get(name) {
+		return time.Time{}, false
+	}
+
+	return time.Unix(entry.Fetched, 0), true
+}
+
+// search returns the cached packages matching word in the field by, like a
+// search on the RPC.
+func (cache *aurCache) search(by, word string) []rpc.Pkg {
+	cache.Lock()
+	defer cache.Unlock()
+
+	results := make([]rpc.Pkg, 0)
+	for _, entry := range cache.pkgs {
+		if matchesField(&entry.Pkg, by, word) {
+			results = append(results, entry.Pkg)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	return results
+}
+
+// useStaleResults records that the results of a search on the cache with a
+// stale entry are used.
+func (cache *aurCache) useStaleResults(results []rpc.Pkg, now time.Time) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for _, pkg := range results {
+		if entry, ok := cache.pkgs[pkg.Name]; ok && cache.stale(entry, now) {
+			cache.usedStale.set(pkg.Name)
+		}
+	}
+}
+
+// aurSearch searches the AUR for word in the field by, or the cache when
+// offline. The results from stale entries are marked as such.
+func aurSearch(by, word string) ([]rpc.Pkg, error) {
+	if config.Offline {
+		cache := loadAURCache()
+		results := cache.search(by, word)
+		cache.useStaleResults(results, time.Now())
+		return results, nil
+	}
+
+	return rpcSearch(by, word)
+}
+
+func pkgNames(pkgs []*rpc.Pkg) []string {
+	names := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+
+	return names
+}
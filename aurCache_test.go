@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestAURCacheLookup(t *testing.T) {
+	now := time.Unix(100000, 0)
+	cache := makeAURCache(10 * time.Minute)
+	cache.update(nil, []*rpc.Pkg{{Name: "old"}}, now.Add(-time.Hour))
+	cache.update(nil, []*rpc.Pkg{{Name: "new"}, {Name: "gone"}}, now.Add(-time.Minute))
+
+	fresh, stale, uncached := cache.lookup([]string{"new", "old", "other"}, now)
+	if names := pkgNames(fresh); len(names) != 1 || names[0] != "new" {
+		t.Errorf("expected fresh [new] got %v", names)
+	}
+	if names := pkgNames(stale); len(names) != 1 || names[0] != "old" {
+		t.Errorf("expected stale [old] got %v", names)
+	}
+	if len(uncached) != 1 || uncached[0] != "other" {
+		t.Errorf("expected uncached [other] got %v", uncached)
+	}
+
+	cache.update([]string{"old", "gone"}, []*rpc.Pkg{{Name: "old"}}, now)
+
+	fresh, stale, uncached = cache.lookup([]string{"old", "gone"}, now)
+	if names := pkgNames(fresh); len(names) != 1 || names[0] != "old" {
+		t.Errorf("expected fresh [old] got %v", names)
+	}
+	if len(stale) != 0 {
+		t.Errorf("expected no stale packages got %v", pkgNames(stale))
+	}
+	if len(uncached) != 1 || uncached[0] != "gone" {
+		t.Errorf("expected uncached [gone] got %v", uncached)
+	}
+}
+
+func TestAURCacheSearch(t *testing.T) {
+	cache := makeAURCache(0)
+	cache.update(nil, []*rpc.Pkg{
+		{Name: "yay", Description: "Yet another yogurt", Maintainer: "jguer"},
+		{Name: "yay-bin", Description: "Pacman wrapper", Maintainer: "jguer"},
+		{Name: "pikaur", Description: "AUR helper with minimal dependencies"},
+	}, time.Now())
+
+	results := cache.search("name-desc", "YOGURT")
+	if len(results) != 1 || results[0].Name != "yay" {
+		t.Errorf("expected [yay] got %v", results)
+	}
+
+	results = cache.search("name-desc", "yay")
+	if len(results) != 2 || results[0].Name != "yay" || results[1].Name != "yay-bin" {
+		t.Errorf("expected [yay yay-bin] got %v", results)
+	}
+
+	results = cache.search("maintainer", "jguer")
+	if len(results) != 2 || results[0].Name != "yay" || results[1].Name != "yay-bin" {
+		t.Errorf("expected [yay yay-bin] got %v", results)
+	}
+
+	if _, stale := cache.staleSince("yay"); stale {
+		t.Errorf("expected yay to not be used stale")
+	}
+	cache.useStale([]*rpc.Pkg{{Name: "yay"}})
+	if _, stale := cache.staleSince("yay"); !stale {
+		t.Errorf("expected yay to be used stale")
+	}
+}
+
+func TestAURCacheSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := aurCacheFile
+	aurCacheFile = filepath.Join(dir, aurCacheFileName)
+	defer func() { aurCacheFile = old }()
+
+	cache := makeAURCache(time.Minute)
+	cache.update(nil, []*rpc.Pkg{{Name: "yay"}}, time.Unix(100000, 0))
+	if err = cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(aurCacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := make(map[string]*aurCacheEntry)
+	if err = json.Unmarshal(data, &pkgs); err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := pkgs["yay"]; !ok || entry.Fetched != 100000 {
+		t.Errorf("expected yay fetched at 100000 got %v", pkgs)
+	}
+
+	if _, err = os.Stat(aurCacheFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be renamed")
+	}
+}
+
+func TestAURCacheUseStaleResults(t *testing.T) {
+	now := time.Unix(100000, 0)
+	cache := makeAURCache(10 * time.Minute)
+	cache.update(nil, []*rpc.Pkg{{Name: "old"}}, now.Add(-time.Hour))
+	cache.update(nil, []*rpc.Pkg{{Name: "new"}}, now)
+
+	cache.useStaleResults([]rpc.Pkg{{Name: "old"}, {Name: "new"}}, now)
+	if _, stale := cache.staleSince("old"); !stale {
+		t.Errorf("expected old to be used stale")
+	}
+	if _, stale := cache.staleSince("new"); stale {
+		t.Errorf("expected new to not be stale")
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// defaultLibraryDirs are the directories the dynamic linker searches by
+// default, relative to the root.
+var defaultLibraryDirs = []string{"usr/lib", "usr/lib32"}
+
+// brokenFile is an ELF file needing libraries that are no longer installed.
+type brokenFile struct {
+	Path    string   `json:"path"`
+	Missing []string `json:"missing"`
+}
+
+// brokenPackage is an installed package with broken ELF files.
+type brokenPackage struct {
+	Name  string       `json:"name"`
+	Base  string       `json:"base"`
+	Files []brokenFile `json:"files"`
+}
+
+// elfNeeded returns the DT_NEEDED entries of the ELF file at path and the
+// directories its RUNPATH and RPATH add to the search. ok is false when path
+// is not a dynamically linked ELF file.
+func elfNeeded(path string) (needed []string, dirs []string, ok bool) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, nil, false
+	}
+	defer f.Close()
+
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+		return nil, nil, false
+	}
+
+	needed, err = f.ImportedLibraries()
+	if err != nil || len(needed) == 0 {
+		return nil, nil, false
+	}
+
+	origin := filepath.Dir(path)
+	for _, tag := range []elf.DynTag{elf.DT_RUNPATH, elf.DT_RPATH} {
+		paths, err := f.DynString(tag)
+		if err != nil {
+			continue
+		}
+
+		for _, p := range paths {
+			for _, dir := range strings.Split(p, ":") {
+				dir = strings.Replace(dir, "${ORIGIN}", origin, -1)
+				dir = strings.Replace(dir, "$ORIGIN", origin, -1)
+				if dir != "" {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+	}
+
+	return needed, dirs, true
+}
+
+// missingLibraries returns the libraries of needed found in none of dirs,
+// either as a file owned by an installed package or on disk. owned holds the
+// full paths of the owned files.
+func missingLibraries(needed []string, owned stringSet, dirs []string) []string {
+	missing := make([]string, 0)
+
+	for _, lib := range needed {
+		found := false
+		for _, dir := range dirs {
+			libPath := filepath.Join(dir, lib)
+			if owned.get(libPath) {
+				found = true
+				break
+			}
+			if _, err := os.Stat(libPath); err == nil {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, lib)
+		}
+	}
+
+	return missing
+}
+
+// findBrokenPackages inspects the files of the foreign packages for ELF files
+// linking libraries no installed package provides anymore, as happens when a
+// repo upgrade bumps a soname.
+func findBrokenPackages() ([]brokenPackage, error) {
+	root, err := alpmHandle.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, remote, _, _, err := filterPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only shared objects can satisfy a DT_NEEDED entry
+	owned := make(stringSet)
+	_ = localDB.PkgCache().ForEach(func(pkg alpm.Package) error {
+		for _, file := range pkg.Files() {
+			if !strings.HasSuffix(file.Name, "/") && strings.Contains(path.Base(file.Name), ".so") {
+				owned.set(filepath.Join(root, file.Name))
+			}
+		}
+		return nil
+	})
+
+	dirs := libraryDirs(root)
+
+	broken := make([]brokenPackage, 0)
+	for _, pkg := range remote {
+		bp := brokenPackage{pkg.Name(), pkg.Base(), make([]brokenFile, 0)}
+		if bp.Base == "" {
+			bp.Base = bp.Name
+		}
+
+		for _, file := range pkg.Files() {
+			fullPath := filepath.Join(root, file.Name)
+			info, err := os.Lstat(fullPath)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if info.Mode()&0111 == 0 && !strings.Contains(path.Base(file.Name), ".so") {
+				continue
+			}
+
+			needed, rpath, ok := elfNeeded(fullPath)
+			if !ok {
+				continue
+			}
+
+			missing := missingLibraries(needed, owned, append(rpath, dirs...))
+			if len(missing) > 0 {
+				bp.Files = append(bp.Files, brokenFile{"/" + file.Name, missing})
+			}
+		}
+
+		if len(bp.Files) > 0 {
+			broken = append(broken, bp)
+		}
+	}
+
+	sort.Slice(broken, func(i, j int) bool { return broken[i].Name < broken[j].Name })
+
+	return broken, nil
+}
+
+// libraryDirs returns the directories the dynamic linker searches under root:
+// the ones listed in etc/ld.so.conf and the files it includes, then the
+// default ones.
+func libraryDirs(root string) []string {
+	dirs := make([]string, 0)
+	seen := make(stringSet)
+	add := func(dir string) {
+		dir = filepath.Join(root, dir)
+		if !seen.get(dir) {
+			seen.set(dir)
+			dirs = append(dirs, dir)
+		}
+	}
+
+	readLdSoConf(root, "/etc/ld.so.conf", add, make(stringSet))
+	for _, dir := range defaultLibraryDirs {
+		add(dir)
+	}
+
+	return dirs
+}
+
+// readLdSoConf passes the directories listed in the ld.so.conf file conf to
+// add, following include lines. Paths are relative to root, relative include
+// patterns to the directory of conf.
+func readLdSoConf(root string, conf string, add func(string), read stringSet) {
+	if read.get(conf) {
+		return
+	}
+	read.set(conf)
+
+	data, err := ioutil.ReadFile(filepath.Join(root, conf))
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+
+		fields := strings.FieldsFunc(line, func(c rune) bool {
+			return c == ' ' || c == '\t' || c == ',' || c == ':' || c == '='
+		})
+		if len(fields) == 0 || fields[0] == "hwcap" {
+			continue
+		}
+
+		if fields[0] != "include" {
+			for _, dir := range fields {
+				add(dir)
+			}
+			continue
+		}
+
+		for _, pattern := range fields[1:] {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(conf), pattern)
+			}
+
+			matches, _ := filepath.Glob(filepath.Join(root, pattern))
+			for _, match := range matches {
+				rel, err := filepath.Rel(root, match)
+				if err == nil {
+					readLdSoConf(root, "/"+rel, add, read)
+				}
+			}
+		}
+	}
+}
+
+func printBrokenPackages(broken []brokenPackage) {
+	fmt.Println(bold(cyan("::")), bold("Packages linking libraries that are no longer installed:"))
+	for _, pkg := range broken {
+		fmt.Println("   ", cyan(pkg.Name))
+		for _, file := range pkg.Files {
+			fmt.Println("       ", file.Path+":", red(strings.Join(file.Missing, " ")))
+		}
+	}
+}
+
+// rebuildBroken rebuilds the bases of the broken packages from the AUR. The
+// packages are targets like with -S --rebuild so that cached builds linked
+// against the old libraries are not installed again.
+func rebuildBroken(broken []brokenPackage) error {
+	arguments := cmdArgs.copyGlobal()
+	arguments.op = "S"
+
+	bases := make(stringSet)
+	for _, pkg := range broken {
+		bases.set(pkg.Base)
+		arguments.addTarget("aur/" + pkg.Name)
+	}
+
+	fmt.Println()
+	if !continueTask(bold(green(fmt.Sprintf("Rebuild %d base(s)?", len(bases)))), false) {
+		return nil
+	}
+
+	if config.ReBuild == "no" {
+		config.ReBuild = "yes"
+	}
+
+	if config.SudoLoop {
+		sudoLoopBackground()
+	}
+
+	return install(arguments)
+}
+
+// checkBrokenPackages prints the broken foreign packages and offers to
+// rebuild them.
+func checkBrokenPackages() error {
+	// The packages may have changed since the handle was opened
+	if err := initAlpmHandle(); err != nil {
+		return err
+	}
+
+	broken, err := findBrokenPackages()
+	if err != nil {
+		return err
+	}
+
+	if config.Format != "" {
+		return printFormatted(os.Stdout, config.Format, broken)
+	}
+
+	if len(broken) == 0 {
+		fmt.Println(bold(cyan("::")), bold("No foreign package links missing libraries"))
+		return nil
+	}
+
+	printBrokenPackages(broken)
+
+	return rebuildBroken(broken)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMissingLibraries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "libonly-on-disk.so.1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	libDir := filepath.Join(dir, "lib")
+	owned := sliceToStringSet([]string{
+		filepath.Join(libDir, "libc.so.6"),
+		filepath.Join(libDir, "libicuuc.so.65"),
+		filepath.Join(dir, "opt", "libssl.so.1.0.0"),
+	})
+	needed := []string{"libc.so.6", "libicuuc.so.64", "libonly-on-disk.so.1", "libssl.so.1.0.0"}
+
+	// A library owned outside the searched directories does not count
+	missing := missingLibraries(needed, owned, []string{libDir, dir})
+	if len(missing) != 2 || missing[0] != "libicuuc.so.64" || missing[1] != "libssl.so.1.0.0" {
+		t.Errorf("expected [libicuuc.so.64 libssl.so.1.0.0] got %v", missing)
+	}
+}
+
+func TestElfNeededNotElf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "script")
+	if err = ioutil.WriteFile(file, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, ok := elfNeeded(file); ok {
+		t.Errorf("expected %s to not be a dynamically linked ELF file", file)
+	}
+}
+
+func TestLibraryDirs(t *testing.T) {
+	root, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	confDir := filepath.Join(root, "etc", "ld.so.conf.d")
+	if err = os.MkdirAll(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		filepath.Join(root, "etc", "ld.so.conf"): "# comment\ninclude /etc/ld.so.conf.d/*.conf\n/opt/lib\n",
+		filepath.Join(confDir, "b.conf"):         "/usr/lib/b\ninclude extra/*.conf\n",
+		filepath.Join(confDir, "a.conf"):         "/usr/lib/a # comment\n\n",
+		filepath.Join(confDir, "c.txt"):          "/usr/lib/c\n",
+		filepath.Join(confDir, "loop.conf"):      "include /etc/ld.so.conf\n",
+	}
+	for file, content := range files {
+		if err = ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.MkdirAll(filepath.Join(confDir, "extra"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(confDir, "extra", "d.conf"), []byte("/usr/lib/d\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := make([]string, 0)
+	for _, dir := range []string{"usr/lib/a", "usr/lib/b", "usr/lib/d", "opt/lib", "usr/lib", "usr/lib32"} {
+		expected = append(expected, filepath.Join(root, dir))
+	}
+
+	dirs := libraryDirs(root)
+	if strings.Join(dirs, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v got %v", expected, dirs)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transactionLog is the log used for commands that do not belong to a single
+// base. Underscores are not allowed in AUR package names so it can not clash.
+const transactionLog = "_transaction"
+
+// logTimeFormat names log files so they sort in the order they were written.
+const logTimeFormat = "2006-01-02T15-04-05"
+
+// logFooter starts the last line of every log, it records how the run ended.
+const logFooter = "==> yay: "
+
+// buildLogs is the log session of the running install. It is nil when build
+// logs are disabled and all logging becomes a no-op.
+var buildLogs *logSession
+
+type buildLog struct {
+	file  *os.File
+	start time.Time
+	code  int
+}
+
+// logSession holds the logs written during a single run of yay, one per base.
+type logSession struct {
+	dir   string
+	stamp string
+	logs  map[string]*buildLog
+	mux   sync.Mutex
+}
+
+func logDir() string {
+	return filepath.Join(cacheHome, "logs")
+}
+
+// startBuildLogs starts logging if build logs are enabled. The returned
+// function closes every log of the session.
+func startBuildLogs() func() {
+	if !config.BuildLogs || buildLogs != nil {
+		return func() {}
+	}
+
+	buildLogs = &logSession{
+		dir:   logDir(),
+		stamp: time.Now().Format(logTimeFormat),
+		logs:  make(map[string]*buildLog),
+	}
+
+	return func() {
+		buildLogs.close()
+		buildLogs = nil
+	}
+}
+
+// get returns the log of pkgbase, opening it on first use. Failing to open a
+// log is reported once and is otherwise ignored.
+func (s *logSession) get(pkgbase string) *buildLog {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if log, ok := s.logs[pkgbase]; ok {
+		return log
+	}
+
+	log := &buildLog{start: time.Now()}
+	s.logs[pkgbase] = log
+
+	dir := filepath.Join(s.dir, pkgbase)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to create build log:", err)
+		return log
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, s.stamp+".log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to create build log:", err)
+		return log
+	}
+
+	fmt.Fprintf(file, "==> yay %s: %s %s\n", version, pkgbase, log.start.Format(time.RFC3339))
+	log.file = file
+	return log
+}
+
+// writer returns a writer to the logs of bases, or the transaction log when
+// no base is given.
+func (s *logSession) writer(bases []string) io.Writer {
+	if len(bases) == 0 {
+		bases = []string{transactionLog}
+	}
+
+	writers := make([]io.Writer, 0, len(bases))
+	for _, base := range bases {
+		if log := s.get(base); log.file != nil {
+			writers = append(writers, log.file)
+		}
+	}
+
+	return io.MultiWriter(writers...)
+}
+
+// finished records the exit status of cmd in the logs of bases.
+func (s *logSession) finished(cmd *exec.Cmd, duration time.Duration, bases []string) {
+	if len(bases) == 0 {
+		bases = []string{transactionLog}
+	}
+
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+
+	for _, base := range bases {
+		log := s.get(base)
+		if code != 0 {
+			log.code = code
+		}
+		if log.file != nil {
+			fmt.Fprintf(log.file, "==> exit status %d after %s\n", code, duration.Round(time.Millisecond))
+		}
+	}
+}
+
+func (s *logSession) close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for base, log := range s.logs {
+		if log.file == nil {
+			continue
+		}
+
+		duration := time.Since(log.start).Round(time.Second)
+		fmt.Fprintf(log.file, "%sstatus=%d duration=%s\n", logFooter, log.code, duration)
+		log.file.Close()
+
+		pruneLogs(filepath.Join(s.dir, base), config.LogRetention)
+	}
+}
+
+// pruneLogs removes all but the newest keep logs in dir.
+func pruneLogs(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+
+	files, err := logFiles(dir)
+	if err != nil || len(files) <= keep {
+		return
+	}
+
+	for _, file := range files[:len(files)-keep] {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// logFiles returns the logs in dir, oldest first.
+func logFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".log") {
+			files = append(files, info.Name())
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// runLog runs cmd with its output going to out, or to the terminal when out is
+// nil, and to the build logs of bases. Like show it returns an empty error
+// when the command fails.
+func runLog(cmd *exec.Cmd, out io.Writer, bases ...string) error {
+	if buildLogs == nil && out == nil {
+		return show(cmd)
+	}
+
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if out != nil {
+		stdout, stderr = out, out
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+
+	if buildLogs != nil {
+		log := buildLogs.writer(bases)
+		fmt.Fprintf(log, "==> %s\n", strings.Join(cmd.Args, " "))
+		stdout = io.MultiWriter(stdout, log)
+		stderr = io.MultiWriter(stderr, log)
+		if out != nil {
+			stderr = stdout
+		}
+	}
+
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	start := time.Now()
+	err := cmd.Run()
+
+	if buildLogs != nil {
+		buildLogs.finished(cmd, time.Since(start), bases)
+	}
+
+	if err != nil {
+		return fmt.Errorf("")
+	}
+	return nil
+}
+
+// showLog is show that also writes the output of cmd to the logs of bases.
+func showLog(cmd *exec.Cmd, bases ...string) error {
+	return runLog(cmd, nil, bases...)
+}
+
+// captureLog is capture that also writes the output of cmd to the logs of
+// bases.
+func captureLog(cmd *exec.Cmd, bases ...string) (string, string, error) {
+	start := time.Now()
+	stdout, stderr, err := capture(cmd)
+
+	if buildLogs != nil {
+		log := buildLogs.writer(bases)
+		fmt.Fprintf(log, "==> %s\n", strings.Join(cmd.Args, " "))
+		for _, out := range []string{stdout, stderr} {
+			if out != "" {
+				fmt.Fprintln(log, out)
+			}
+		}
+		buildLogs.finished(cmd, time.Since(start), bases)
+	}
+
+	return stdout, stderr, err
+}
+
+// logEntry describes a log on disk for -P --logs.
+type logEntry struct {
+	base     string
+	path     string
+	time     time.Time
+	status   string
+	duration string
+}
+
+func readLogEntry(dir, base, file string) logEntry {
+	entry := logEntry{base, filepath.Join(dir, base, file), time.Time{}, "running", "-"}
+	entry.time, _ = time.ParseInLocation(logTimeFormat, strings.TrimSuffix(file, ".log"), time.Local)
+
+	f, err := os.Open(entry.path)
+	if err != nil {
+		return entry
+	}
+	defer f.Close()
+
+	// The footer is always on the last line, no need to read the whole log
+	buf := make([]byte, 256)
+	if info, err := f.Stat(); err == nil && info.Size() > int64(len(buf)) {
+		f.Seek(-int64(len(buf)), io.SeekEnd)
+	}
+	n, _ := io.ReadFull(f, buf)
+	buf = bytes.TrimRight(buf[:n], "\n")
+
+	i := bytes.LastIndex(buf, []byte("\n"+logFooter))
+	if i < 0 {
+		return entry
+	}
+
+	for _, field := range strings.Fields(string(buf[i+1+len(logFooter):])) {
+		split := strings.SplitN(field, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		switch split[0] {
+		case "status":
+			if split[1] == "0" {
+				entry.status = "ok"
+			} else {
+				entry.status = "exit " + split[1]
+			}
+		case "duration":
+			entry.duration = split[1]
+		}
+	}
+
+	return entry
+}
+
+// printBuildLogs lists the recent build logs. Given a package base it lists
+// the logs of that base and opens the newest one in $PAGER.
+func printBuildLogs(bases []string) error {
+	dir := logDir()
+
+	if len(bases) == 0 {
+		infos, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no build logs in %s", dir)
+		} else if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			if info.IsDir() {
+				bases = append(bases, info.Name())
+			}
+		}
+	}
+
+	entries := make([]logEntry, 0)
+	for _, base := range bases {
+		if strings.Contains(base, "/") || strings.Contains(base, "..") {
+			return fmt.Errorf("invalid package base: %s", base)
+		}
+
+		files, err := logFiles(filepath.Join(dir, base))
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no build logs for %s", base)
+		} else if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			entries = append(entries, readLogEntry(dir, base, file))
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.Before(entries[j].time)
+	})
+
+	if len(bases) != 1 && len(entries) > 20 {
+		entries = entries[len(entries)-20:]
+	}
+
+	for _, entry := range entries {
+		status := green(entry.status)
+		if entry.status != "ok" {
+			status = red(entry.status)
+		}
+
+		fmt.Printf("%s %s %s %s\n    %s\n",
+			entry.time.Format("2006-01-02 15:04:05"), bold(entry.base), status, entry.duration, entry.path)
+	}
+
+	if len(bases) != 1 || len(entries) == 0 {
+		return nil
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+
+	args := strings.Fields(pager)
+	args = append(args, entries[len(entries)-1].path)
+	return show(exec.Command(args[0], args[1:]...))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2019, 12, 31, 23, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		name := start.Add(time.Duration(i) * time.Hour).Format(logTimeFormat)
+		if err = ioutil.WriteFile(filepath.Join(dir, name+".log"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(filepath.Join(dir, "old.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pruneLogs(dir, 0)
+	if files, _ := logFiles(dir); len(files) != 4 {
+		t.Fatalf("expected a retention of 0 to keep every log got %v", files)
+	}
+
+	pruneLogs(dir, 2)
+	files, err := logFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "2020-01-01T01-00-00.log 2020-01-01T02-00-00.log"
+	if strings.Join(files, " ") != expected {
+		t.Errorf("expected %s got %v", expected, files)
+	}
+
+	for _, name := range []string{"notes.txt", "old.log"} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be left alone: %s", name, err)
+		}
+	}
+}
+
+func TestPrintBuildLogsInvalidBase(t *testing.T) {
+	for _, base := range []string{"../foo", "foo/bar", ".."} {
+		if err := printBuildLogs([]string{base}); err == nil {
+			t.Errorf("expected an error for %q", base)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	buildSucceeded = "succeeded"
+	buildFailed    = "failed"
+	buildSkipped   = "skipped"
+)
+
+// buildResult is the outcome of building a single AUR base.
+type buildResult struct {
+	Pkgbase  string
+	Version  string
+	Status   string
+	ExitCode int
+	Reason   string
+}
+
+// buildSummary collects the result of every base handled by
+// buildInstallPkgbuilds when running with --keepgoing.
+type buildSummary struct {
+	results []*buildResult
+	broken  []Base
+}
+
+func (bs *buildSummary) succeeded(base Base) {
+	bs.results = append(bs.results, &buildResult{base.Pkgbase(), base.Version(), buildSucceeded, 0, ""})
+}
+
+func (bs *buildSummary) failed(base Base, cmd *exec.Cmd) {
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+
+	bs.broken = append(bs.broken, base)
+	bs.results = append(bs.results, &buildResult{base.Pkgbase(), base.Version(), buildFailed, code, "makepkg exited with status " + strconv.Itoa(code)})
+}
+
+// installFailed records a base that was built but could not be installed.
+func (bs *buildSummary) installFailed(base Base, err error) {
+	bs.broken = append(bs.broken, base)
+	bs.results = append(bs.results, &buildResult{base.Pkgbase(), base.Version(), buildFailed, -1, "install failed: " + err.Error()})
+}
+
+func (bs *buildSummary) skipped(base Base, blocker string) {
+	bs.broken = append(bs.broken, base)
+	bs.results = append(bs.results, &buildResult{base.Pkgbase(), base.Version(), buildSkipped, 0, "depends on " + blocker})
+}
+
+// blockedBy returns the failed or skipped base that base depends on, if any.
+// Bases are built in dependency order so skipping dependents as we go covers
+// transitive dependencies too.
+func (bs *buildSummary) blockedBy(base Base) string {
+	for _, pkg := range base {
+		for _, deps := range buildDeps(pkg) {
+			for _, dep := range deps {
+				for _, broken := range bs.broken {
+					for _, bpkg := range broken {
+						if satisfiesAur(dep, bpkg) {
+							return broken.Pkgbase()
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// failedBase reports whether base failed to build or install.
+func (bs *buildSummary) failedBase(base Base) bool {
+	for _, result := range bs.results {
+		if result.Pkgbase == base.Pkgbase() && result.Status == buildFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (bs *buildSummary) count(status string) int {
+	n := 0
+	for _, result := range bs.results {
+		if result.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (bs *buildSummary) print() {
+	if len(bs.results) == 0 {
+		return
+	}
+
+	width := 0
+	for _, result := range bs.results {
+		if l := len(result.Pkgbase + "-" + result.Version); l > width {
+			width = l
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(bold(cyan("::") + bold(" Build summary")))
+	for _, result := range bs.results {
+		name := result.Pkgbase + "-" + result.Version
+		name += strings.Repeat(" ", width-len(name))
+
+		var status string
+		switch result.Status {
+		case buildSucceeded:
+			status = green(fmt.Sprintf("%-10s", result.Status))
+		case buildFailed:
+			status = red(fmt.Sprintf("%-10s", result.Status))
+		default:
+			status = yellow(fmt.Sprintf("%-10s", result.Status))
+		}
+
+		fmt.Println("   ", cyan(name), bold(status), result.Reason)
+	}
+}
+
+// err returns an error when any base failed or had to be skipped.
+func (bs *buildSummary) err() error {
+	if len(bs.broken) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d failed and %d skipped of %d AUR bases",
+		bs.count(buildFailed), bs.count(buildSkipped), len(bs.results))
+}
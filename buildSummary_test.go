@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuildSummaryBlockedBy(t *testing.T) {
+	old := config
+	config = defaultSettings()
+	config.MFlags = "--check"
+	defer func() { config = old }()
+
+	lib := Base{{Name: "lib", PackageBase: "libs", Version: "1-1"}, {Name: "lib-utils", PackageBase: "libs", Version: "1-1", Provides: []string{"utils"}}}
+	tool := Base{{Name: "tool", PackageBase: "tool", Version: "1-1", MakeDepends: []string{"lib"}}}
+	app := Base{{Name: "app", PackageBase: "app", Version: "1-1", Depends: []string{"tool"}}}
+	test := Base{{Name: "test", PackageBase: "test", Version: "1-1", CheckDepends: []string{"utils"}}}
+	other := Base{{Name: "other", PackageBase: "other", Version: "1-1", Depends: []string{"glibc"}}}
+
+	bs := &buildSummary{}
+	bs.installFailed(lib, fmt.Errorf("conflicting files"))
+
+	if blocker := bs.blockedBy(tool); blocker != "libs" {
+		t.Errorf("expected tool to be blocked by libs got %q", blocker)
+	}
+	bs.skipped(tool, "libs")
+
+	// Dependents of skipped bases are blocked too
+	if blocker := bs.blockedBy(app); blocker != "tool" {
+		t.Errorf("expected app to be blocked by tool got %q", blocker)
+	}
+	if blocker := bs.blockedBy(test); blocker != "libs" {
+		t.Errorf("expected test to be blocked by libs through a provide got %q", blocker)
+	}
+	if blocker := bs.blockedBy(other); blocker != "" {
+		t.Errorf("expected other not to be blocked got %q", blocker)
+	}
+
+	if !bs.failedBase(lib) || bs.failedBase(tool) {
+		t.Error("expected only libs to have failed")
+	}
+
+	bs.succeeded(other)
+	if bs.count(buildFailed) != 1 || bs.count(buildSkipped) != 1 || bs.count(buildSucceeded) != 1 {
+		t.Errorf("unexpected results %v", bs.results)
+	}
+	if bs.err() == nil {
+		t.Error("expected an error when bases failed")
+	}
+	if (&buildSummary{results: []*buildResult{{Status: buildSucceeded}}}).err() != nil {
+		t.Error("expected no error when every base succeeded")
+	}
+
+	// checkdepends do not block when checks do not run
+	config.MFlags = "--nocheck"
+	if blocker := bs.blockedBy(test); blocker != "" {
+		t.Errorf("expected test not to be blocked without checks got %q", blocker)
+	}
+}
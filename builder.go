@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// builder builds AUR package bases. Bumping the pkgver and listing the
+// packages a base produces always happens on the host, only the build itself
+// is handed to the builder.
+type builder interface {
+	// init prepares the build environment once per transaction, for
+	// building the bases of do.
+	init(dp *depPool, do *depOrder) error
+	// pkgver returns the command updating the pkgver of the base in dir.
+	pkgver(dir string, incompatible bool) *exec.Cmd
+	// build returns the command building the base in dir. deps holds the
+	// package files of the AUR dependencies built earlier in the transaction.
+	build(dir string, deps []string, incompatible bool) *exec.Cmd
+}
+
+func newBuilder() builder {
+	if config.UseChroot {
+		return &chrootBuilder{chrootDir(), nil}
+	}
+
+	return hostBuilder{}
+}
+
+// hostBuilder runs makepkg directly on the host.
+type hostBuilder struct{}
+
+func (hostBuilder) init(dp *depPool, do *depOrder) error {
+	return nil
+}
+
+func (hostBuilder) pkgver(dir string, incompatible bool) *exec.Cmd {
+	args := []string{"--nobuild", "-fC"}
+
+	if incompatible {
+		args = append(args, "--ignorearch")
+	}
+
+	return passToMakepkg(dir, args...)
+}
+
+func (hostBuilder) build(dir string, deps []string, incompatible bool) *exec.Cmd {
+	args := []string{"-cf", "--noconfirm", "--noextract", "--noprepare", "--holdver"}
+
+	if incompatible {
+		args = append(args, "--ignorearch")
+	}
+
+	return passToMakepkg(dir, args...)
+}
+
+// chrootBuilder builds in a clean chroot using the devtools scripts. The
+// pristine chroot lives in dir/root and each build runs in a fresh copy of it,
+// named after the package base, so nothing installed on the host leaks into
+// the packages. hostDeps holds the package files of the installed AUR
+// packages each base needs, by package base.
+type chrootBuilder struct {
+	dir      string
+	hostDeps map[string][]string
+}
+
+func chrootDir() string {
+	if config.ChrootDir != "" {
+		return config.ChrootDir
+	}
+
+	return filepath.Join(cacheHome, "chroot")
+}
+
+// init creates the chroot on first use and brings it up to date otherwise.
+func (b *chrootBuilder) init(dp *depPool, do *depOrder) error {
+	var err error
+	if b.hostDeps, err = hostDepFiles(dp, do); err != nil {
+		return err
+	}
+
+	root := filepath.Join(b.dir, "root")
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		if err = os.MkdirAll(b.dir, 0755); err != nil {
+			return fmt.Errorf("Failed to create chroot directory '%s': %s", b.dir, err)
+		}
+
+		fmt.Println(bold(cyan("::")), bold("Creating chroot:"), cyan(root))
+		args := []string{"mkarchroot", "-C", config.PacmanConf}
+		if config.MakepkgConf != "" {
+			args = append(args, "-M", config.MakepkgConf)
+		}
+		args = append(args, root, "base-devel")
+
+		if err = showLog(exec.Command("sudo", args...)); err != nil {
+			return fmt.Errorf("Error creating chroot: %s", root)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Println(bold(cyan("::")), bold("Updating chroot:"), cyan(root))
+	err = showLog(exec.Command("sudo", "arch-nspawn", root, "pacman", "-Syu", "--noconfirm"))
+	if err != nil {
+		return fmt.Errorf("Error updating chroot: %s", root)
+	}
+
+	return nil
+}
+
+// pkgver runs on the host without dependency checks, the build dependencies
+// only exist inside the chroot.
+func (b *chrootBuilder) pkgver(dir string, incompatible bool) *exec.Cmd {
+	args := []string{"--nobuild", "--nodeps", "-fC"}
+
+	if incompatible {
+		args = append(args, "--ignorearch")
+	}
+
+	return passToMakepkg(dir, args...)
+}
+
+func (b *chrootBuilder) build(dir string, deps []string, incompatible bool) *exec.Cmd {
+	args := []string{"-f", "--noconfirm", "--holdver"}
+
+	if incompatible {
+		args = append(args, "--ignorearch")
+	}
+
+	// Each base gets a copy of its own so parallel builds do not share one
+	pkgbase := filepath.Base(dir)
+	deps = append(deps, b.hostDeps[pkgbase]...)
+	return passToMakechrootpkg(dir, b.dir, pkgbase, deps, args...)
+}
+
+// depFiles returns the package files of the bases built so far that base i
+// depends on, directly or through other bases. A clean chroot has none of
+// them installed so they all have to be handed to the build.
+func depFiles(do *depOrder, graph [][]int, i int, built map[string]map[string]string) []string {
+	files := make([]string, 0)
+	seen := make(map[int]bool)
+
+	var walk func(int)
+	walk = func(i int) {
+		for _, j := range graph[i] {
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+			walk(j)
+
+			for _, file := range built[do.Aur[j].Pkgbase()] {
+				if _, err := os.Stat(file); err == nil {
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	walk(i)
+
+	return files
+}
+
+// hostDepFiles returns, by package base, the package files of the installed
+// AUR packages the bases of do depend on and that are not built in this
+// transaction, along with the ones those depend on in turn. The chroot only
+// has what the repos provide, so they have to be installed into it.
+func hostDepFiles(dp *depPool, do *depOrder) (map[string][]string, error) {
+	files := make(map[string][]string)
+
+	inTransaction := func(dep string) bool {
+		for _, base := range do.Aur {
+			for _, pkg := range base {
+				if satisfiesAur(dep, pkg) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, base := range do.Aur {
+		pkgbase := base.Pkgbase()
+		seen := make(stringSet)
+
+		var walk func(dep string) error
+		walk = func(dep string) error {
+			if inTransaction(dep) {
+				return nil
+			}
+			if _, err := dp.SyncDB.FindSatisfier(dep); err == nil {
+				return nil
+			}
+
+			pkg, err := dp.LocalDB.PkgCache().FindSatisfier(dep)
+			if err != nil || seen.get(pkg.Name()) {
+				return nil
+			}
+			seen.set(pkg.Name())
+
+			file := findPackageFile(packageFileDirs(pkg.Base()), pkg.Name(), pkg.Version())
+			if file == "" {
+				return fmt.Errorf("Could not find the package file of %s-%s to install into the chroot", pkg.Name(), pkg.Version())
+			}
+			files[pkgbase] = append(files[pkgbase], file)
+
+			return pkg.Depends().ForEach(func(dep alpm.Depend) error {
+				return walk(dep.String())
+			})
+		}
+
+		for _, pkg := range base {
+			for _, deps := range buildDeps(pkg) {
+				for _, dep := range deps {
+					if err := walk(dep); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// packageFileDirs returns the directories package files of pkgbase are kept
+// in: the pacman cache and the build dir of the base.
+func packageFileDirs(pkgbase string) []string {
+	dirs := append([]string{}, pacmanConf.CacheDir...)
+	return append(dirs, filepath.Join(config.BuildDir, pkgbase))
+}
+
+// findPackageFile returns the package file of name at version in dirs, or an
+// empty string when there is none.
+func findPackageFile(dirs []string, name string, version string) string {
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, name+"-"+version+"-*.pkg.tar*"))
+		for _, match := range matches {
+			if !strings.HasSuffix(match, ".sig") {
+				return match
+			}
+		}
+	}
+
+	return ""
+}
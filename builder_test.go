@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDepFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := func(name string) string {
+		path := filepath.Join(dir, name+"-1-1-x86_64.pkg.tar.xz")
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	do := makeDepOrder()
+	for _, name := range []string{"libc", "lib", "tool", "app"} {
+		do.Aur = append(do.Aur, Base{{Name: name, PackageBase: name}})
+	}
+	// app needs lib and tool, lib needs libc
+	graph := [][]int{nil, {0}, nil, {1, 2}}
+
+	built := map[string]map[string]string{
+		"libc": {"libc": file("libc")},
+		"lib":  {"lib": file("lib"), "lib-docs": filepath.Join(dir, "removed.pkg.tar.xz")},
+	}
+
+	files := depFiles(do, graph, 3, built)
+	expected := []string{built["libc"]["libc"], built["lib"]["lib"]}
+	if strings.Join(files, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v got %v", expected, files)
+	}
+
+	if files = depFiles(do, graph, 2, built); len(files) != 0 {
+		t.Errorf("expected no files for tool got %v", files)
+	}
+}
+
+func TestPassToMakechrootpkg(t *testing.T) {
+	old := config
+	config = defaultSettings()
+	config.MFlags = "--skippgpcheck"
+	defer func() { config = old }()
+
+	cmd := passToMakechrootpkg("/build/foo", "/chroot", "foo", []string{"/pkg/bar.pkg.tar.xz"}, "-f")
+	expected := "makechrootpkg -c -r /chroot -l foo -I /pkg/bar.pkg.tar.xz -- -f --skippgpcheck"
+	if args := strings.Join(cmd.Args, " "); args != expected {
+		t.Errorf("expected %q got %q", expected, args)
+	}
+	if cmd.Dir != "/build/foo" {
+		t.Errorf("expected to run in /build/foo got %s", cmd.Dir)
+	}
+}
+
+func TestFindPackageFile(t *testing.T) {
+	cache, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	build, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(build)
+
+	for _, path := range []string{
+		filepath.Join(cache, "foo-bar-1.0-1-x86_64.pkg.tar.xz"),
+		filepath.Join(build, "foo-1.0-1-x86_64.pkg.tar.xz.sig"),
+		filepath.Join(build, "foo-1.0-1-x86_64.pkg.tar.xz"),
+		filepath.Join(build, "foo-0.9-1-x86_64.pkg.tar.xz"),
+	} {
+		if err = ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirs := []string{cache, build}
+	if file := findPackageFile(dirs, "foo", "1.0-1"); file != filepath.Join(build, "foo-1.0-1-x86_64.pkg.tar.xz") {
+		t.Errorf("expected foo-1.0-1 from the build dir got %q", file)
+	}
+	if file := findPackageFile(dirs, "foo", "1.1-1"); file != "" {
+		t.Errorf("expected no file got %q", file)
+	}
+}
@@ -19,17 +19,21 @@ func questionCallback(question alpm.QuestionAny) {
 		return
 	}
 
-	if hideMenus {
-		return
-	}
-
-	size := 0
-
+	names := make([]string, 0)
 	qp.Providers(alpmHandle).ForEach(func(pkg alpm.Package) error {
-		size++
+		names = append(names, pkg.Name())
 		return nil
 	})
 
+	index, settled := config.Providers.choose(qp.Dep().Name, names)
+	qp.SetUseIndex(index)
+
+	if settled || hideMenus {
+		return
+	}
+
+	size := len(names)
+
 	fmt.Print(bold(cyan(":: ")))
 	str := bold(fmt.Sprintf(bold("There are %d providers available for %s:"), size, qp.Dep()))
 
@@ -51,7 +55,7 @@ func questionCallback(question alpm.QuestionAny) {
 	fmt.Println(str)
 
 	for {
-		fmt.Print("\nEnter a number (default=1): ")
+		fmt.Printf("\nEnter a number (default=%d): ", index+1)
 
 		if config.NoConfirm {
 			fmt.Println()
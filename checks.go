@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// makepkgChecks caches whether makepkg.conf enables the check() functions.
+var makepkgChecks struct {
+	once    sync.Once
+	enabled bool
+}
+
+// baseChecks holds the check option of the PKGBUILDs that set one, true for
+// check and false for !check.
+var baseChecks = struct {
+	sync.Mutex
+	options map[string]bool
+}{options: make(map[string]bool)}
+
+// makepkgConfFiles returns the makepkg.conf files makepkg reads, in order.
+// The *.conf files of the makepkg.conf.d directory next to the config are
+// read after it. The user's makepkg.conf is only read when no other config is
+// given.
+func makepkgConfFiles() []string {
+	if config.MakepkgConf != "" {
+		return withConfDir(config.MakepkgConf)
+	}
+
+	files := withConfDir("/etc/makepkg.conf")
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		if _, err := os.Stat(filepath.Join(xdg, "pacman", "makepkg.conf")); err == nil {
+			return append(files, filepath.Join(xdg, "pacman", "makepkg.conf"))
+		}
+	}
+
+	return append(files, filepath.Join(os.Getenv("HOME"), ".makepkg.conf"))
+}
+
+// withConfDir returns conf followed by the *.conf files of conf.d sorted by
+// name.
+func withConfDir(conf string) []string {
+	dropins, _ := filepath.Glob(filepath.Join(conf+".d", "*.conf"))
+	return append([]string{conf}, dropins...)
+}
+
+// parseBuildenvCheck returns the check option a makepkg.conf sets in
+// BUILDENV: "check", "!check" or "" when it sets neither. The file is shell,
+// only plain BUILDENV=(...) and BUILDENV+=(...) assignments are understood.
+func parseBuildenvCheck(conf string, current string) string {
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		var value string
+		appending := false
+		switch {
+		case strings.HasPrefix(line, "BUILDENV=("):
+			value = strings.TrimPrefix(line, "BUILDENV=(")
+		case strings.HasPrefix(line, "BUILDENV+=("):
+			value = strings.TrimPrefix(line, "BUILDENV+=(")
+			appending = true
+		default:
+			continue
+		}
+
+		if i := strings.Index(value, ")"); i != -1 {
+			value = value[:i]
+		}
+
+		if !appending {
+			current = ""
+		}
+		for _, option := range strings.Fields(value) {
+			option = strings.Trim(option, "'\"")
+			if option == "check" || option == "!check" {
+				current = option
+			}
+		}
+	}
+
+	return current
+}
+
+// checkFlag returns --check or --nocheck when passed to makepkg through
+// --mflags, the last one wins.
+func checkFlag() string {
+	flag := ""
+	for _, arg := range strings.Fields(config.MFlags) {
+		if arg == "--check" || arg == "--nocheck" {
+			flag = arg
+		}
+	}
+
+	return flag
+}
+
+// setBaseChecks records the check option of the PKGBUILD of pkgbase.
+func setBaseChecks(pkgbase string, srcinfo *gosrc.Srcinfo) {
+	baseChecks.Lock()
+	defer baseChecks.Unlock()
+
+	for _, option := range srcinfo.Options {
+		switch option {
+		case "check":
+			baseChecks.options[pkgbase] = true
+		case "!check":
+			baseChecks.options[pkgbase] = false
+		}
+	}
+}
+
+// runsChecks reports whether makepkg will run the check() function of
+// pkgbase. Like makepkg, --check and --nocheck win over the PKGBUILD options
+// which win over BUILDENV. Checks are off unless something enables them.
+func runsChecks(pkgbase string) bool {
+	switch checkFlag() {
+	case "--check":
+		return true
+	case "--nocheck":
+		return false
+	}
+
+	baseChecks.Lock()
+	enabled, ok := baseChecks.options[pkgbase]
+	baseChecks.Unlock()
+	if ok {
+		return enabled
+	}
+
+	makepkgChecks.once.Do(func() {
+		check := ""
+		for _, file := range makepkgConfFiles() {
+			if conf, err := ioutil.ReadFile(file); err == nil {
+				check = parseBuildenvCheck(string(conf), check)
+			}
+		}
+		makepkgChecks.enabled = check == "check"
+	})
+
+	return makepkgChecks.enabled
+}
+
+// resolveCheckDeps resolves the pool again once the check options of the
+// bases in do are known. They are only read from the .SRCINFO files, after
+// the pool was first resolved. The checkdepends of bases that will run checks
+// are pulled into the pool and ordering it again leaves out the ones of bases
+// that will not. It returns the new order and whether it differs from do.
+func (dp *depPool) resolveCheckDeps(do *depOrder) (*depOrder, bool, error) {
+	deps := make(stringSet)
+	for _, base := range do.Aur {
+		if !runsChecks(base.Pkgbase()) {
+			continue
+		}
+		for _, pkg := range base {
+			for _, dep := range pkg.CheckDepends {
+				deps.set(dep)
+			}
+		}
+	}
+
+	pooled := len(dp.Aur) + len(dp.Repo)
+	if err := dp.resolveAURDeps(deps); err != nil {
+		return do, false, err
+	}
+	if len(dp.Aur)+len(dp.Repo) != pooled {
+		if err := dp.solve(); err != nil {
+			return do, false, err
+		}
+		if err := dp.CheckMissing(); err != nil {
+			return do, false, err
+		}
+	}
+
+	newDo, cycles := orderDeps(dp)
+	before := do.names()
+	after := newDo.names()
+	changed := len(before) != len(after)
+	for name := range after {
+		changed = changed || !before.get(name)
+	}
+
+	if !changed {
+		return do, false, nil
+	}
+
+	if len(cycles) > 0 {
+		printCycles(dp, cycles)
+		if config.AbortCycle {
+			return do, false, fmt.Errorf("")
+		}
+	}
+
+	// Packages left out of the order are not installed, keep them from
+	// being checked for conflicts
+	for name := range dp.Aur {
+		if !after.get(name) {
+			delete(dp.Aur, name)
+		}
+	}
+	for name := range dp.Repo {
+		if !after.get(name) {
+			delete(dp.Repo, name)
+		}
+	}
+
+	return newDo, true, nil
+}
+
+// buildDeps returns the dependencies needed to build and install pkg:
+// depends, makedepends and checkdepends when its checks will run.
+func buildDeps(pkg *rpc.Pkg) [][]string {
+	if !runsChecks(pkg.PackageBase) {
+		return [][]string{pkg.Depends, pkg.MakeDepends}
+	}
+
+	return [][]string{pkg.Depends, pkg.MakeDepends, pkg.CheckDepends}
+}
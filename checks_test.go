@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	alpm "github.com/Jguer/go-alpm"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestParseBuildenvCheck(t *testing.T) {
+	inputs := []struct {
+		Conf    string
+		Current string
+	}{
+		{"BUILDENV=(!distcc color !ccache check !sign)", ""},
+		{"BUILDENV=(!distcc color !ccache !check !sign)", "check"},
+		{"#BUILDENV=(check)\nBUILDENV=(color)", "check"},
+		{"BUILDENV+=(!check)", "check"},
+		{"BUILDENV+=(color)", "check"},
+		{"  BUILDENV=('check' \"!sign\")  # comment", "!check"},
+		{"OPTIONS=(!check)", ""},
+	}
+	expected := []string{"check", "!check", "", "!check", "check", "check", ""}
+
+	for n, in := range inputs {
+		if check := parseBuildenvCheck(in.Conf, in.Current); check != expected[n] {
+			t.Fatalf("Test %d Failed: Expected %q got %q", n+1, expected[n], check)
+		}
+	}
+}
+
+func TestRunsChecksFlags(t *testing.T) {
+	old := config
+	config = defaultSettings()
+	defer func() { config = old }()
+
+	baseChecks.options["foo"] = true
+	defer delete(baseChecks.options, "foo")
+
+	config.MFlags = "--skippgpcheck --nocheck"
+	if runsChecks("foo") {
+		t.Fatal("Expected --nocheck to disable checks")
+	}
+	if deps := buildDeps(&rpc.Pkg{PackageBase: "foo", CheckDepends: []string{"bar"}}); len(deps) != 2 {
+		t.Fatalf("Expected checkdepends to be dropped got %v", deps)
+	}
+
+	config.MFlags = "--nocheck --check"
+	baseChecks.options["foo"] = false
+	if !runsChecks("foo") {
+		t.Fatal("Expected --check to enable checks")
+	}
+
+	config.MFlags = ""
+	if runsChecks("foo") {
+		t.Fatal("Expected options=(!check) to disable checks")
+	}
+}
+
+func TestResolveCheckDeps(t *testing.T) {
+	old := config
+	config = defaultSettings()
+	defer func() { config = old }()
+
+	app := &rpc.Pkg{Name: "app", PackageBase: "app", CheckDepends: []string{"checker"}}
+	dp := &depPool{
+		Targets:  []target{toTarget("app")},
+		Explicit: make(stringSet),
+		Repo:     make(map[string]*alpm.Package),
+		Aur:      map[string]*rpc.Pkg{"app": app, "checker": {Name: "checker", PackageBase: "checker"}},
+	}
+
+	// Resolved before the .SRCINFO enabled the checks
+	do := makeDepOrder()
+	do.Aur = []Base{{app}}
+
+	baseChecks.options["app"] = true
+	defer delete(baseChecks.options, "app")
+
+	do, changed, err := dp.resolveCheckDeps(do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(do.Aur) != 2 || do.Aur[0].Pkgbase() != "checker" {
+		t.Fatalf("Expected checker to be built before app got %v", do.Aur)
+	}
+
+	baseChecks.options["app"] = false
+	do, changed, err = dp.resolveCheckDeps(do)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(do.Aur) != 1 {
+		t.Fatalf("Expected checker to be left out got %v", do.Aur)
+	}
+	if _, ok := dp.Aur["checker"]; ok {
+		t.Fatal("Expected checker to be removed from the pool")
+	}
+
+	if _, changed, _ = dp.resolveCheckDeps(do); changed {
+		t.Fatal("Expected nothing to change")
+	}
+}
+
+func TestMakepkgConfFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := filepath.Join(dir, "makepkg.conf")
+	os.MkdirAll(conf+".d", 0755)
+	for _, name := range []string{"b.conf", "a.conf", "c.txt"} {
+		ioutil.WriteFile(filepath.Join(conf+".d", name), nil, 0644)
+	}
+
+	old := config
+	config = defaultSettings()
+	defer func() { config = old }()
+	config.MakepkgConf = conf
+
+	expected := []string{conf, filepath.Join(conf+".d", "a.conf"), filepath.Join(conf+".d", "b.conf")}
+	files := makepkgConfFiles()
+	if strings.Join(files, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected %v got %v", expected, files)
+	}
+}
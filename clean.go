@@ -95,7 +95,20 @@ func syncClean(parser *arguments) error {
 	}
 
 	if continueTask("Do you want to remove ALL untracked AUR files?", true) {
-		return cleanUntracked()
+		err = cleanUntracked()
+		if err != nil {
+			return err
+		}
+	}
+
+	repo, err := getLocalRepo()
+	if err != nil || repo == nil {
+		return err
+	}
+
+	fmt.Printf("\nLocal repo directory: %s\n", repo.dir)
+	if continueTask("Do you want to remove stale packages from the local repo?", true) {
+		return repo.clean()
 	}
 
 	return nil
@@ -149,6 +162,13 @@ func cleanAUR(keepInstalled, keepCurrent, removeAll bool) error {
 		}
 	}
 
+	// Keep the sources of everything in the local repo so it can be rebuilt
+	if repo, err := getLocalRepo(); err == nil && repo != nil {
+		for base := range repo.bases() {
+			installedBases.set(base)
+		}
+	}
+
 	for _, file := range files {
 		if !file.IsDir() {
 			continue
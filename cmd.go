@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"net/http"
 	"os"
 
 	alpm "github.com/Jguer/go-alpm"
@@ -36,6 +35,8 @@ New operations:
 New options:
        --repo             Assume targets are from the repositories
     -a --aur              Assume targets are from the AUR
+       --offline          Use cached AUR data instead of querying the AUR
+       --format <fmt>     Print results as json or with a Go template
 
 Permanent configuration options:
     --save                Causes the following options to be saved back to the
@@ -111,20 +112,69 @@ Permanent configuration options:
     --timeupdate          Check packages' AUR page for changes during sysupgrade
     --notimeupdate        Do not check packages' AUR page for changes
 
+    --keepgoing           Skip AUR packages that fail to build and their dependents
+    --nokeepgoing         Stop at the first AUR package that fails to build
+
+    --buildjobs <n>       Build up to n independent AUR packages at the same time
+
+    --chroot[=dir]        Build AUR packages in a clean chroot
+    --nochroot            Build AUR packages on the host
+
+    --localrepo <repo>    Add built AUR packages to a local repo and install from it
+    --nolocalrepo         Install built AUR packages with pacman -U
+
+    --buildlogs           Save the output of each AUR build to a log
+    --nobuildlogs         Do not save build logs
+    --logretention <n>    Number of build logs to keep for each package base
+    --provider <dep=pkg>  Package to use when several provide a dependency
+    --srcinfodeps         Resolve AUR dependencies again from the PKGBUILDs
+    --nosrcinfodeps       Trust the dependencies reported by the AUR
+    --abortcycle          Abort when AUR packages depend on each other
+    --noabortcycle        Warn about dependency cycles and build anyway
+    --brokencheck         Look for packages linking missing libraries after -Syu
+    --nobrokencheck       Do not look for broken packages after -Syu
+    --aurcachettl <n>     Reuse cached AUR data for n minutes
+    --aurmetadata         Use the AUR metadata archive instead of the RPC
+    --noaurmetadata       Query the RPC for AUR packages
+    --requesttimeout <n>  Seconds to wait for a server to connect or respond
+    --requestretries <n>  Times to retry a failed request
+
 show specific options:
     -c --complete         Used for completions
     -d --defaultconfig    Print default yay configuration
     -g --currentconfig    Print current yay configuration
     -s --stats            Display system package statistics
+       --broken           With -s, find foreign packages linking missing libraries
+       --logs [pkgbase]   List build logs or view the newest log of a base
     -w --news             Print arch news
 
 yay specific options:
     -c --clean            Remove unneeded dependencies
        --gendb            Generates development package DB used for updating
+       --resume           Continue the last AUR transaction that failed to build
+       --history [id]     List installed transactions or show one in detail
+       --rollback <id>    Reinstall the AUR packages a transaction replaced
+       --downgrade        Build and install an older revision of an AUR package
+       --hold             Hold AUR packages back, optionally to a version range
+       --unhold           Stop holding AUR packages back
+       --holds            List held packages
 
 getpkgbuild specific options:
     -f --force            Force download for existing tar packages
 
+sync specific options:
+       --print-plan[=fmt] Resolve the transaction and print the plan instead of
+                          installing anything. fmt is human (default) or json
+       --why <pkg>        Show why the transaction would install a package
+       --graph[=fmt]      Print the dependency graph of the transaction instead
+                          of installing anything. fmt is dot (default) or json
+       --by <field>       Search the AUR by field, such as maintainer or depends
+
+query specific options:
+       --why <pkg>        Show why an installed package is installed
+       --graph[=fmt]      Print the dependency graph of the installed packages
+       --maintainer <m>   List the installed AUR packages maintained by m
+
 If no arguments are provided 'yay -Syu' will be performed
 If no operation is provided -Y will be assumed`)
 }
@@ -172,6 +222,15 @@ func handleCmd() (err error) {
 }
 
 func handleQuery() error {
+	if name, _, exists := cmdArgs.getArg("why"); exists {
+		return whyInstalled(name)
+	}
+	if name, _, exists := cmdArgs.getArg("maintainer"); exists {
+		return printMaintainedBy(cmdArgs, name)
+	}
+	if cmdArgs.existsArg("graph") {
+		return printLocalGraph(cmdArgs.targets)
+	}
 	if cmdArgs.existsArg("u", "upgrades") {
 		return printUpdateList(cmdArgs)
 	}
@@ -208,8 +267,12 @@ func handlePrint() (err error) {
 		complete(true)
 	case cmdArgs.existsArg("c", "complete"):
 		complete(false)
+	case cmdArgs.existsArg("s", "stats") && cmdArgs.existsArg("broken"):
+		err = checkBrokenPackages()
 	case cmdArgs.existsArg("s", "stats"):
 		err = localStatistics()
+	case cmdArgs.existsArg("logs"):
+		err = printBuildLogs(cmdArgs.targets)
 	default:
 		err = nil
 	}
@@ -221,6 +284,30 @@ func handleYay() error {
 	if cmdArgs.existsArg("gendb") {
 		return createDevelDB()
 	}
+	if cmdArgs.existsArg("resume") {
+		return resumeInstall()
+	}
+	if cmdArgs.existsArg("history") {
+		return printHistory(cmdArgs.targets)
+	}
+	if id, _, exists := cmdArgs.getArg("rollback"); exists {
+		return rollback(id)
+	}
+	if cmdArgs.existsArg("hold") {
+		return holdPackages(cmdArgs.targets)
+	}
+	if cmdArgs.existsArg("unhold") {
+		return unholdPackages(cmdArgs.targets)
+	}
+	if cmdArgs.existsArg("holds") {
+		return printHolds()
+	}
+	if cmdArgs.existsArg("downgrade") {
+		if len(cmdArgs.targets) != 1 {
+			return fmt.Errorf("--downgrade takes a single package")
+		}
+		return downgrade(cmdArgs.targets[0])
+	}
 	if cmdArgs.existsDouble("c") {
 		return cleanDependencies(true)
 	}
@@ -268,6 +355,12 @@ func handleSync() error {
 	if cmdArgs.existsArg("i", "info") {
 		return syncInfo(targets)
 	}
+	if name, _, exists := cmdArgs.getArg("why"); exists {
+		return whyPlanned(name, targets)
+	}
+	if cmdArgs.existsArg("graph") {
+		return printPlannedGraph(targets)
+	}
 	if cmdArgs.existsArg("u", "sysupgrade") {
 		return install(cmdArgs)
 	}
@@ -281,8 +374,22 @@ func handleSync() error {
 }
 
 func handleRemove() error {
-	removeVCSPackage(cmdArgs.targets)
-	return show(passToPacman(cmdArgs))
+	pkgs, err := localRemovalPkgs()
+	if err != nil {
+		return err
+	}
+
+	planRemoval(pkgs, cmdArgs.targets).print(cmdArgs.existsArg("s", "recursive"))
+
+	if err = show(passToPacman(cmdArgs)); err != nil {
+		return err
+	}
+
+	if cmdArgs.existsArg("p", "print") {
+		return nil
+	}
+
+	return cleanRemovedForeign(pkgs)
 }
 
 // NumberMenu presents a CLI for selecting packages to install.
@@ -417,21 +524,20 @@ func syncList(parser *arguments) error {
 			return err
 		}
 
-		resp, err := http.Get(config.AURURL + "/packages.gz")
+		names, versions, err := aurPackageList()
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-
-		scanner := bufio.NewScanner(resp.Body)
 
-		scanner.Scan()
-		for scanner.Scan() {
-			name := scanner.Text()
+		for _, name := range names {
 			if cmdArgs.existsArg("q", "quiet") {
 				fmt.Println(name)
 			} else {
-				fmt.Printf("%s %s %s", magenta("aur"), bold(name), bold(green("unknown-version")))
+				version, ok := versions[name]
+				if !ok {
+					version = "unknown-version"
+				}
+				fmt.Printf("%s %s %s", magenta("aur"), bold(name), bold(green(version)))
 
 				if localDB.Pkg(name) != nil {
 					fmt.Print(bold(blue(" [Installed]")))
@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,19 +10,44 @@ import (
 	alpm "github.com/Jguer/go-alpm"
 )
 
-//CreateAURList creates a new completion file
-func createAURList(out *os.File) (err error) {
-	resp, err := http.Get(config.AURURL + "/packages.gz")
+// aurPackageList returns the names of the AUR packages and, when the metadata
+// archive is used, their versions. Otherwise packages.gz is downloaded, it
+// holds the names only.
+func aurPackageList() ([]string, map[string]string, error) {
+	versions := make(map[string]string)
+	if archive := loadAURArchive(); archive != nil {
+		for name, pkg := range archive.pkgs {
+			versions[name] = pkg.Version
+		}
+		return archive.names, versions, nil
+	}
+
+	resp, err := httpGet(config.AURURL + "/packages.gz")
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
+	names := make([]string, 0)
 	scanner := bufio.NewScanner(resp.Body)
 
 	scanner.Scan()
 	for scanner.Scan() {
-		out.WriteString(scanner.Text())
+		names = append(names, scanner.Text())
+	}
+
+	return names, versions, scanner.Err()
+}
+
+//CreateAURList creates a new completion file
+func createAURList(out *os.File) (err error) {
+	names, _, err := aurPackageList()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		out.WriteString(name)
 		out.WriteString("\tAUR\n")
 	}
 
@@ -48,6 +48,20 @@ type Configuration struct {
 	ReDownload         string `json:"redownload"`
 	ReBuild            string `json:"rebuild"`
 	BatchInstall       bool   `json:"batchinstall"`
+	KeepGoing          bool   `json:"keepgoing"`
+	BuildJobs          int    `json:"buildjobs"`
+	UseChroot          bool   `json:"chroot"`
+	ChrootDir          string `json:"chrootdir"`
+	LocalRepo          string `json:"localrepo"`
+	BuildLogs          bool   `json:"buildlogs"`
+	LogRetention       int    `json:"logretention"`
+	AURCacheTTL        int    `json:"aurcachettl"`
+	AURMetadata        bool   `json:"aurmetadata"`
+	RequestTimeout     int    `json:"requesttimeout"`
+	RequestRetries     int    `json:"requestretries"`
+	SrcinfoDeps        bool   `json:"srcinfodeps"`
+	AbortCycle         bool   `json:"abortcycle"`
+	BrokenCheck        bool   `json:"brokencheck"`
 	AnswerClean        string `json:"answerclean"`
 	AnswerDiff         string `json:"answerdiff"`
 	AnswerEdit         string `json:"answeredit"`
@@ -66,6 +80,9 @@ type Configuration struct {
 	SudoLoop           bool   `json:"sudoloop"`
 	TimeUpdate         bool   `json:"timeupdate"`
 	NoConfirm          bool   `json:"-"`
+	Offline            bool   `json:"-"`
+	PrintPlan          string `json:"-"`
+	Format             string `json:"-"`
 	Devel              bool   `json:"devel"`
 	CleanAfter         bool   `json:"cleanAfter"`
 	GitClone           bool   `json:"gitclone"`
@@ -77,6 +94,10 @@ type Configuration struct {
 	EditMenu           bool   `json:"editmenu"`
 	CombinedUpgrade    bool   `json:"combinedupgrade"`
 	UseAsk             bool   `json:"useask"`
+
+	// Providers maps dependencies to the package that should provide them,
+	// see providerPrefs.
+	Providers providerPrefs `json:"providers"`
 }
 
 var version = "9.2.1"
@@ -87,6 +108,12 @@ const configFileName string = "config.json"
 // vcsFileName holds the name of the vcs file.
 const vcsFileName string = "vcs.json"
 
+// holdsFileName holds the name of the holds file.
+const holdsFileName string = "holds.json"
+
+// aurCacheFileName holds the name of the AUR metadata cache file.
+const aurCacheFileName string = "aur.json"
+
 // useColor enables/disables colored printing
 var useColor bool
 
@@ -99,12 +126,21 @@ var cacheHome string
 // savedInfo holds the current vcs info
 var savedInfo vcsInfo
 
+// holds holds the packages held by yay
+var holds holdSet
+
 // configfile holds yay config file path.
 var configFile string
 
 // vcsfile holds yay vcs info file path.
 var vcsFile string
 
+// holdsFile holds yay holds file path.
+var holdsFile string
+
+// aurCacheFile holds yay AUR metadata cache file path.
+var aurCacheFile string
+
 // shouldSaveConfig holds whether or not the config should be saved
 var shouldSaveConfig bool
 
@@ -167,6 +203,20 @@ func defaultSettings() *Configuration {
 		ReDownload:         "no",
 		ReBuild:            "no",
 		BatchInstall:       false,
+		KeepGoing:          false,
+		BuildJobs:          1,
+		UseChroot:          false,
+		ChrootDir:          "",
+		LocalRepo:          "",
+		BuildLogs:          true,
+		LogRetention:       10,
+		AURCacheTTL:        10,
+		AURMetadata:        false,
+		RequestTimeout:     30,
+		RequestRetries:     3,
+		SrcinfoDeps:        false,
+		AbortCycle:         false,
+		BrokenCheck:        false,
 		AnswerClean:        "",
 		AnswerDiff:         "",
 		AnswerEdit:         "",
@@ -180,6 +230,7 @@ func defaultSettings() *Configuration {
 		EditMenu:           false,
 		UseAsk:             false,
 		CombinedUpgrade:    false,
+		Providers:          make(providerPrefs),
 	}
 
 	if os.Getenv("XDG_CACHE_HOME") != "" {
@@ -192,6 +243,7 @@ func defaultSettings() *Configuration {
 func (config *Configuration) expandEnv() {
 	config.AURURL = os.ExpandEnv(config.AURURL)
 	config.BuildDir = os.ExpandEnv(config.BuildDir)
+	config.ChrootDir = os.ExpandEnv(config.ChrootDir)
 	config.Editor = os.ExpandEnv(config.Editor)
 	config.EditorFlags = os.ExpandEnv(config.EditorFlags)
 	config.MakepkgBin = os.ExpandEnv(config.MakepkgBin)
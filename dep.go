@@ -64,6 +64,34 @@ func splitDep(dep string) (string, string, string) {
 	return split[0], mod, split[1]
 }
 
+// dependStrings returns the dependencies of list as strings, with their
+// version constraints.
+func dependStrings(list alpm.DependList) []string {
+	deps := make([]string, 0)
+	_ = list.ForEach(func(dep alpm.Depend) error {
+		deps = append(deps, dep.String())
+		return nil
+	})
+
+	return deps
+}
+
+// optDependStrings is dependStrings for optional dependencies, keeping the
+// description after the dependency as in a PKGBUILD.
+func optDependStrings(list alpm.DependList) []string {
+	deps := make([]string, 0)
+	_ = list.ForEach(func(dep alpm.Depend) error {
+		str := dep.String()
+		if dep.Description != "" {
+			str += ": " + dep.Description
+		}
+		deps = append(deps, str)
+		return nil
+	})
+
+	return deps
+}
+
 func pkgSatisfies(name, version, dep string) bool {
 	depName, depMod, depVersion := splitDep(dep)
 
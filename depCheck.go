@@ -187,7 +187,7 @@ func (dp *depPool) CheckConflicts() (mapStringSet, error) {
 		}
 	}
 
-	if len(conflicts) > 0 {
+	if len(conflicts) > 0 && config.PrintPlan == "" {
 		if !config.UseAsk {
 			if config.NoConfirm {
 				return nil, fmt.Errorf("Package conflicts can not be resolved with noconfirm, aborting")
@@ -205,6 +205,7 @@ func (dp *depPool) CheckConflicts() (mapStringSet, error) {
 type missing struct {
 	Good    stringSet
 	Missing map[string][][]string
+	Held    map[string][][]string
 }
 
 func (dp *depPool) _checkMissing(dep string, stack []string, missing *missing) {
@@ -224,8 +225,18 @@ func (dp *depPool) _checkMissing(dep string, stack []string, missing *missing) {
 
 	aurPkg := dp.findSatisfierAur(dep)
 	if aurPkg != nil {
+		installed := ""
+		if pkg := dp.LocalDB.Pkg(aurPkg.Name); pkg != nil {
+			installed = pkg.Version()
+		}
+
+		if !holds.allows(aurPkg.Name, aurPkg.Version, installed) {
+			missing.Held[aurPkg.Name] = append(missing.Held[aurPkg.Name], stack)
+			return
+		}
+
 		missing.Good.set(dep)
-		for _, deps := range [3][]string{aurPkg.Depends, aurPkg.MakeDepends, aurPkg.CheckDepends} {
+		for _, deps := range buildDeps(aurPkg) {
 			for _, aurDep := range deps {
 				if _, err := dp.LocalDB.PkgCache().FindSatisfier(aurDep); err == nil {
 					missing.Good.set(aurDep)
@@ -258,39 +269,80 @@ func (dp *depPool) _checkMissing(dep string, stack []string, missing *missing) {
 	missing.Missing[dep] = [][]string{stack}
 }
 
-func (dp *depPool) CheckMissing() error {
+// findMissing returns the dependencies of the targets the pool does not
+// satisfy.
+func (dp *depPool) findMissing() *missing {
 	missing := &missing{
 		make(stringSet),
 		make(map[string][][]string),
+		make(map[string][][]string),
 	}
 
 	for _, target := range dp.Targets {
 		dp._checkMissing(target.DepString(), make([]string, 0), missing)
 	}
 
-	if len(missing.Missing) == 0 {
-		return nil
+	return missing
+}
+
+// versionConstraints returns whether a missing dependency has a version
+// constraint, another version of it may then satisfy it.
+func (missing *missing) versionConstraints() bool {
+	for dep := range missing.Missing {
+		if _, mod, _ := splitDep(dep); mod != "" {
+			return true
+		}
 	}
 
-	fmt.Println(bold(red(arrow+" Error: ")) + "Could not find all required packages:")
-	for dep, trees := range missing.Missing {
-		for _, tree := range trees {
+	return false
+}
 
-			fmt.Print("    ", cyan(dep))
+func (dp *depPool) CheckMissing() error {
+	missing := dp.findMissing()
 
-			if len(tree) == 0 {
-				fmt.Print(" (Target")
-			} else {
-				fmt.Print(" (Wanted by: ")
-				for n := 0; n < len(tree)-1; n++ {
-					fmt.Print(cyan(tree[n]), " -> ")
-				}
-				fmt.Print(cyan(tree[len(tree)-1]))
+	if len(missing.Missing) == 0 && len(missing.Held) == 0 {
+		return nil
+	}
+
+	if len(missing.Missing) > 0 {
+		fmt.Println(bold(red(arrow+" Error: ")) + "Could not find all required packages:")
+		for dep, trees := range missing.Missing {
+			for _, tree := range trees {
+				printWantedBy(cyan(dep), tree)
+			}
+		}
+	}
+
+	if len(missing.Held) > 0 {
+		fmt.Println(bold(red(arrow+" Error: ")) + "Required packages are held back, use --unhold to release them:")
+		for name, trees := range missing.Held {
+			installed := ""
+			if pkg := dp.LocalDB.Pkg(name); pkg != nil {
+				installed = pkg.Version()
 			}
 
-			fmt.Println(")")
+			pkg := cyan(name+"-"+dp.Aur[name].Version) + " held at " + holds.describe(name, installed)
+			for _, tree := range trees {
+				printWantedBy(pkg, tree)
+			}
 		}
 	}
 
 	return fmt.Errorf("")
 }
+
+func printWantedBy(pkg string, tree []string) {
+	fmt.Print("    ", pkg)
+
+	if len(tree) == 0 {
+		fmt.Print(" (Target")
+	} else {
+		fmt.Print(" (Wanted by: ")
+		for n := 0; n < len(tree)-1; n++ {
+			fmt.Print(cyan(tree[n]), " -> ")
+		}
+		fmt.Print(cyan(tree[len(tree)-1]))
+	}
+
+	fmt.Println(")")
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// Kinds of dependency between two packages in a depGraph.
+const (
+	edgeDepends      = "depends"
+	edgeMakeDepends  = "makedepends"
+	edgeCheckDepends = "checkdepends"
+	edgeOptDepends   = "optdepends"
+	edgeGroup        = "group"
+)
+
+// depEdge is a dependency of from on to. dep is the dependency as written by
+// from, it names something else than to when to satisfies it through its
+// provides.
+type depEdge struct {
+	from string
+	to   string
+	kind string
+	dep  string
+}
+
+// provided returns the name to provides when the edge goes through a provide.
+func (e depEdge) provided() string {
+	name, _, _ := splitDep(e.dep)
+	if name == e.to {
+		return ""
+	}
+
+	return name
+}
+
+// depNode is a package in a depGraph. root is set for the packages wanted
+// for their own sake, explicitly installed packages or the targets of a
+// transaction. unneeded is set for installed packages no root needs.
+type depNode struct {
+	name     string
+	version  string
+	source   string
+	root     bool
+	unneeded bool
+}
+
+// depGraph is a graph of packages and the dependencies between them.
+type depGraph struct {
+	nodes map[string]*depNode
+	edges map[string][]depEdge
+}
+
+func makeDepGraph() *depGraph {
+	return &depGraph{
+		make(map[string]*depNode),
+		make(map[string][]depEdge),
+	}
+}
+
+func (g *depGraph) addNode(name, version, source string, root bool) {
+	if _, ok := g.nodes[name]; !ok {
+		g.nodes[name] = &depNode{name, version, source, root, false}
+	}
+}
+
+func (g *depGraph) addEdge(from, to, kind, dep string) {
+	for _, edge := range g.edges[from] {
+		if edge.to == to && edge.kind == kind {
+			return
+		}
+	}
+
+	g.edges[from] = append(g.edges[from], depEdge{from, to, kind, dep})
+}
+
+// names returns the names of all nodes, sorted.
+func (g *depGraph) names() []string {
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// reverse returns the edges of the graph indexed by the package they point to.
+func (g *depGraph) reverse() map[string][]depEdge {
+	reverse := make(map[string][]depEdge)
+
+	for _, name := range g.names() {
+		for _, edge := range g.edges[name] {
+			reverse[edge.to] = append(reverse[edge.to], edge)
+		}
+	}
+
+	return reverse
+}
+
+// reachable returns the packages that can be reached from the given packages,
+// including themselves.
+func (g *depGraph) reachable(from []string) stringSet {
+	seen := make(stringSet)
+
+	var walk func(string)
+	walk = func(name string) {
+		if seen.get(name) {
+			return
+		}
+		seen.set(name)
+		for _, edge := range g.edges[name] {
+			walk(edge.to)
+		}
+	}
+
+	for _, name := range from {
+		if _, ok := g.nodes[name]; ok {
+			walk(name)
+		}
+	}
+
+	return seen
+}
+
+// subgraph returns the part of the graph made of the given packages.
+func (g *depGraph) subgraph(names stringSet) *depGraph {
+	sub := makeDepGraph()
+
+	for name := range names {
+		if node, ok := g.nodes[name]; ok {
+			sub.nodes[name] = node
+		}
+	}
+
+	for name := range sub.nodes {
+		for _, edge := range g.edges[name] {
+			if names.get(edge.to) {
+				sub.edges[name] = append(sub.edges[name], edge)
+			}
+		}
+	}
+
+	return sub
+}
+
+// stripOptDesc removes the description from an optdepends entry as returned
+// by the AUR, "foo: for foo support" becomes "foo".
+func stripOptDesc(dep string) string {
+	if i := strings.Index(dep, ": "); i != -1 {
+		dep = dep[:i]
+	}
+
+	return strings.TrimSuffix(dep, ":")
+}
+
+// localDepGraph builds the graph of the installed packages. Explicitly
+// installed packages are the roots.
+func localDepGraph() (*depGraph, error) {
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return nil, err
+	}
+
+	g := makeDepGraph()
+	localDB.PkgCache().ForEach(func(pkg alpm.Package) error {
+		g.addNode(pkg.Name(), pkg.Version(), "local", pkg.Reason() == alpm.PkgReasonExplicit)
+		return nil
+	})
+
+	addEdges := func(pkg alpm.Package, kind string, deps alpm.DependList) {
+		deps.ForEach(func(dep alpm.Depend) error {
+			if satisfier, err := localDB.PkgCache().FindSatisfier(dep.String()); err == nil {
+				g.addEdge(pkg.Name(), satisfier.Name(), kind, dep.String())
+			}
+			return nil
+		})
+	}
+
+	localDB.PkgCache().ForEach(func(pkg alpm.Package) error {
+		addEdges(pkg, edgeDepends, pkg.Depends())
+		addEdges(pkg, edgeMakeDepends, pkg.MakeDepends())
+		addEdges(pkg, edgeCheckDepends, pkg.CheckDepends())
+		addEdges(pkg, edgeOptDepends, pkg.OptionalDepends())
+		return nil
+	})
+
+	return g, nil
+}
+
+// depGraph builds the graph of the packages the transaction would install.
+// The targets are the roots. Dependencies that are already installed end the
+// graph, their own dependencies are not followed.
+func (dp *depPool) depGraph() *depGraph {
+	g := makeDepGraph()
+
+	for _, pkg := range dp.Aur {
+		g.addNode(pkg.Name, pkg.Version, "aur", dp.Explicit.get(pkg.Name))
+	}
+	for _, pkg := range dp.Repo {
+		g.addNode(pkg.Name(), pkg.Version(), pkg.DB().Name(), dp.Explicit.get(pkg.Name()))
+	}
+
+	// pacman resolves groups itself, only their packages are known
+	for _, group := range dp.Groups {
+		db, name := splitDBFromName(group)
+		g.addNode(group, "", "group", true)
+
+		dp.SyncDB.FindGroupPkgs(name).ForEach(func(pkg alpm.Package) error {
+			if db == "" || pkg.DB().Name() == db {
+				g.addNode(pkg.Name(), pkg.Version(), pkg.DB().Name(), false)
+				g.addEdge(group, pkg.Name(), edgeGroup, pkg.Name())
+			}
+			return nil
+		})
+	}
+
+	addEdge := func(from, kind, dep string) {
+		if pkg := dp.findSatisfierAur(dep); pkg != nil {
+			g.addEdge(from, pkg.Name, kind, dep)
+		} else if pkg := dp.findSatisfierRepo(dep); pkg != nil {
+			g.addEdge(from, pkg.Name(), kind, dep)
+		} else if pkg, err := dp.LocalDB.PkgCache().FindSatisfier(dep); err == nil {
+			g.addNode(pkg.Name(), pkg.Version(), "local", false)
+			g.addEdge(from, pkg.Name(), kind, dep)
+		}
+	}
+
+	for _, pkg := range dp.Aur {
+		for _, dep := range pkg.Depends {
+			addEdge(pkg.Name, edgeDepends, dep)
+		}
+		for _, dep := range pkg.MakeDepends {
+			addEdge(pkg.Name, edgeMakeDepends, dep)
+		}
+		for _, dep := range pkg.CheckDepends {
+			addEdge(pkg.Name, edgeCheckDepends, dep)
+		}
+		for _, dep := range pkg.OptDepends {
+			addEdge(pkg.Name, edgeOptDepends, stripOptDesc(dep))
+		}
+	}
+
+	for _, pkg := range dp.Repo {
+		pkg.Depends().ForEach(func(dep alpm.Depend) error {
+			addEdge(pkg.Name(), edgeDepends, dep.String())
+			return nil
+		})
+		pkg.OptionalDepends().ForEach(func(dep alpm.Depend) error {
+			addEdge(pkg.Name(), edgeOptDepends, dep.String())
+			return nil
+		})
+	}
+
+	return g
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	alpm "github.com/Jguer/go-alpm"
 	rpc "github.com/mikkeloscar/aur"
 )
@@ -33,76 +35,269 @@ func makeDepOrder() *depOrder {
 	}
 }
 
-func getDepOrder(dp *depPool) *depOrder {
-	do := makeDepOrder()
+// depOrderer walks the pool from the targets to put their dependencies first.
+// path holds the edges from the target being ordered to the package being
+// ordered so that edges leading back to a package on it can be reported as
+// cycles.
+type depOrderer struct {
+	do       *depOrder
+	dp       *depPool
+	aurDone  stringSet
+	repoDone stringSet
+	onPath   stringSet
+	path     []depEdge
+	cycles   [][]depEdge
+}
+
+// orderDeps orders the packages of the pool and returns the dependency cycles
+// found among AUR bases.
+func orderDeps(dp *depPool) (*depOrder, [][]depEdge) {
+	o := &depOrderer{
+		makeDepOrder(),
+		dp,
+		make(stringSet),
+		make(stringSet),
+		make(stringSet),
+		make([]depEdge, 0),
+		make([][]depEdge, 0),
+	}
 
 	for _, target := range dp.Targets {
 		dep := target.DepString()
 		aurPkg := dp.Aur[dep]
 		if aurPkg != nil && pkgSatisfies(aurPkg.Name, aurPkg.Version, dep) {
-			do.orderPkgAur(aurPkg, dp, true)
+			o.orderPkgAur(aurPkg, true)
 		}
 
 		aurPkg = dp.findSatisfierAur(dep)
 		if aurPkg != nil {
-			do.orderPkgAur(aurPkg, dp, true)
+			o.orderPkgAur(aurPkg, true)
 		}
 
 		repoPkg := dp.findSatisfierRepo(dep)
 		if repoPkg != nil {
-			do.orderPkgRepo(repoPkg, dp, true)
+			o.orderPkgRepo(repoPkg, true)
 		}
 	}
 
-	return do
+	return o.do, o.cycles
 }
 
-func (do *depOrder) orderPkgAur(pkg *rpc.Pkg, dp *depPool, runtime bool) {
+// getDepOrder orders the packages of the pool. Dependency cycles between AUR
+// bases are reported, the build order of the bases in a cycle is arbitrary so
+// the install is aborted when --abortcycle is set.
+func getDepOrder(dp *depPool) (*depOrder, error) {
+	do, cycles := orderDeps(dp)
+	if len(cycles) == 0 {
+		return do, nil
+	}
+
+	printCycles(dp, cycles)
+	if config.AbortCycle {
+		return do, fmt.Errorf("")
+	}
+
+	return do, nil
+}
+
+func (o *depOrderer) orderPkgAur(pkg *rpc.Pkg, runtime bool) {
+	if o.aurDone.get(pkg.Name) {
+		return
+	}
 	if runtime {
-		do.Runtime.set(pkg.Name)
+		o.do.Runtime.set(pkg.Name)
 	}
-	delete(dp.Aur, pkg.Name)
+	o.aurDone.set(pkg.Name)
+	o.onPath.set(pkg.Name)
 
-	for i, deps := range [3][]string{pkg.Depends, pkg.MakeDepends, pkg.CheckDepends} {
+	kinds := [3]string{edgeDepends, edgeMakeDepends, edgeCheckDepends}
+	for i, deps := range buildDeps(pkg) {
 		for _, dep := range deps {
-			aurPkg := dp.findSatisfierAur(dep)
+			aurPkg := o.dp.findSatisfierAur(dep)
 			if aurPkg != nil {
-				do.orderPkgAur(aurPkg, dp, runtime && i == 0)
+				edge := depEdge{pkg.Name, aurPkg.Name, kinds[i], dep}
+				if o.onPath.get(aurPkg.Name) {
+					o.addCycle(edge)
+				} else {
+					o.path = append(o.path, edge)
+					o.orderPkgAur(aurPkg, runtime && i == 0)
+					o.path = o.path[:len(o.path)-1]
+				}
 			}
 
-			repoPkg := dp.findSatisfierRepo(dep)
+			repoPkg := o.dp.findSatisfierRepo(dep)
 			if repoPkg != nil {
-				do.orderPkgRepo(repoPkg, dp, runtime && i == 0)
+				o.orderPkgRepo(repoPkg, runtime && i == 0)
 			}
 		}
 	}
 
-	for i, base := range do.Aur {
+	o.onPath.remove(pkg.Name)
+
+	for i, base := range o.do.Aur {
 		if base.Pkgbase() == pkg.PackageBase {
-			do.Aur[i] = append(base, pkg)
+			o.do.Aur[i] = append(base, pkg)
 			return
 		}
 	}
 
-	do.Aur = append(do.Aur, Base{pkg})
+	o.do.Aur = append(o.do.Aur, Base{pkg})
 }
 
-func (do *depOrder) orderPkgRepo(pkg *alpm.Package, dp *depPool, runtime bool) {
+func (o *depOrderer) orderPkgRepo(pkg *alpm.Package, runtime bool) {
+	if o.repoDone.get(pkg.Name()) {
+		return
+	}
 	if runtime {
-		do.Runtime.set(pkg.Name())
+		o.do.Runtime.set(pkg.Name())
 	}
-	delete(dp.Repo, pkg.Name())
+	o.repoDone.set(pkg.Name())
 
 	pkg.Depends().ForEach(func(dep alpm.Depend) (err error) {
-		repoPkg := dp.findSatisfierRepo(dep.String())
+		repoPkg := o.dp.findSatisfierRepo(dep.String())
 		if repoPkg != nil {
-			do.orderPkgRepo(repoPkg, dp, runtime)
+			o.orderPkgRepo(repoPkg, runtime)
 		}
 
 		return nil
 	})
 
-	do.Repo = append(do.Repo, pkg)
+	o.do.Repo = append(o.do.Repo, pkg)
+}
+
+// addCycle records the cycle closed by edge. Packages of the same base are
+// built together so a cycle within a base is not one.
+func (o *depOrderer) addCycle(edge depEdge) {
+	start := 0
+	for i, pathEdge := range o.path {
+		if pathEdge.from == edge.to {
+			start = i
+			break
+		}
+	}
+
+	cycle := append(append([]depEdge{}, o.path[start:]...), edge)
+
+	pkgbase := o.dp.Aur[edge.to].PackageBase
+	for _, cycleEdge := range cycle {
+		if o.dp.Aur[cycleEdge.from].PackageBase != pkgbase {
+			o.cycles = append(o.cycles, cycle)
+			return
+		}
+	}
+}
+
+// printCycles prints the dependency cycles along with the edges that could be
+// broken to build them in a sane order.
+func printCycles(dp *depPool, cycles [][]depEdge) {
+	if config.AbortCycle {
+		fmt.Println(bold(red(arrow+" Error: ")) + "Dependency cycles found between AUR packages:")
+	} else {
+		fmt.Println(bold(yellow(arrow+" Warning: ")) + "Dependency cycles found between AUR packages, the build order is arbitrary:")
+	}
+
+	for _, cycle := range cycles {
+		fmt.Println("   ", formatDepPath(cycle))
+
+		suggested := false
+		for _, edge := range cycle {
+			if edge.kind == edgeDepends {
+				continue
+			}
+
+			if pkg, err := dp.LocalDB.PkgCache().FindSatisfier(edge.dep); err == nil {
+				fmt.Printf("      %s could be built first, its %s on %s is satisfied by the installed %s\n",
+					cyan(edge.from), edge.kind, cyan(edge.dep), cyan(pkg.Name()))
+				suggested = true
+			}
+		}
+		if suggested {
+			continue
+		}
+
+		for _, edge := range cycle {
+			if edge.kind != edgeDepends {
+				fmt.Printf("      installing a package providing %s first would break the %s of %s\n",
+					cyan(edge.dep), edge.kind, cyan(edge.from))
+				suggested = true
+				break
+			}
+		}
+		if !suggested {
+			fmt.Println("      the packages depend on each other at runtime, one of them has to be installed by hand first")
+		}
+	}
+}
+
+// aurDeps returns the dependency graph of do.Aur. For every base it lists the
+// indexes of the other bases that satisfy one of its depends, makedepends or
+// checkdepends. Bases sharing no edge can be built at the same time. Edges
+// closing a cycle are left out, the bases in it would wait on each other.
+func (do *depOrder) aurDeps() [][]int {
+	graph := make([][]int, len(do.Aur))
+
+	for i, base := range do.Aur {
+		seen := make(map[int]bool)
+		for _, pkg := range base {
+			for _, deps := range buildDeps(pkg) {
+				for _, dep := range deps {
+					for j, other := range do.Aur {
+						if i == j || seen[j] {
+							continue
+						}
+
+						for _, otherPkg := range other {
+							if satisfiesAur(dep, otherPkg) {
+								seen[j] = true
+								graph[i] = append(graph[i], j)
+								break
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	state := make([]int, len(graph))
+	var visit func(int)
+	visit = func(i int) {
+		state[i] = 1
+		kept := graph[i][:0]
+		for _, j := range graph[i] {
+			if state[j] == 1 {
+				continue
+			}
+			if state[j] == 0 {
+				visit(j)
+			}
+			kept = append(kept, j)
+		}
+		graph[i] = kept
+		state[i] = 2
+	}
+	for i := range graph {
+		if state[i] == 0 {
+			visit(i)
+		}
+	}
+
+	return graph
+}
+
+// names returns the names of the packages in do.
+func (do *depOrder) names() stringSet {
+	names := make(stringSet)
+	for _, base := range do.Aur {
+		for _, pkg := range base {
+			names.set(pkg.Name)
+		}
+	}
+	for _, pkg := range do.Repo {
+		names.set(pkg.Name())
+	}
+
+	return names
 }
 
 func (do *depOrder) HasMake() bool {
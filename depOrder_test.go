@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	alpm "github.com/Jguer/go-alpm"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func testCyclePool() *depPool {
+	pkgs := []*rpc.Pkg{
+		{Name: "app", PackageBase: "app", Depends: []string{"foo-git"}},
+		{Name: "foo-git", PackageBase: "foo-git", MakeDepends: []string{"bar-git"}},
+		{Name: "bar-git", PackageBase: "bar-git", Depends: []string{"libfoo-git"}},
+		{Name: "libfoo-git", PackageBase: "foo-git", Depends: []string{"foo-git"}},
+	}
+
+	dp := &depPool{
+		Targets:  []target{toTarget("app")},
+		Explicit: make(stringSet),
+		Repo:     make(map[string]*alpm.Package),
+		Aur:      make(map[string]*rpc.Pkg),
+	}
+	for _, pkg := range pkgs {
+		dp.Aur[pkg.Name] = pkg
+	}
+
+	return dp
+}
+
+func TestOrderDepsCycle(t *testing.T) {
+	dp := testCyclePool()
+	do, cycles := orderDeps(dp)
+
+	if len(cycles) != 1 {
+		t.Fatalf("Expected 1 cycle got %d", len(cycles))
+	}
+
+	path := formatPathsPlain(cycles)[0]
+	if expected := "foo-git makedepends bar-git depends libfoo-git depends foo-git"; path != expected {
+		t.Fatalf("Expected %s got %s", expected, path)
+	}
+
+	if len(do.Aur) != 3 || do.Aur[len(do.Aur)-1].Pkgbase() != "app" {
+		t.Fatalf("Expected 3 bases ending with app got %v", do.Aur)
+	}
+	if !do.Runtime.get("foo-git") || do.Runtime.get("bar-git") {
+		t.Fatalf("Unexpected runtime packages: %v", do.Runtime)
+	}
+
+	// The pool is left untouched
+	if len(dp.Aur) != 4 {
+		t.Fatalf("Expected the pool to keep 4 packages got %d", len(dp.Aur))
+	}
+
+	// Every base must still be able to start
+	graph := do.aurDeps()
+	for i := range graph {
+		for _, j := range graph[i] {
+			for _, k := range graph[j] {
+				if k == i {
+					t.Fatalf("Bases %d and %d wait on each other", i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestOrderDepsSplitBase(t *testing.T) {
+	dp := testCyclePool()
+	dp.Aur["app"].Depends = []string{"libfoo-git"}
+	dp.Aur["foo-git"].MakeDepends = nil
+	dp.Aur["foo-git"].Depends = []string{"libfoo-git"}
+
+	// foo-git and libfoo-git depending on each other is within a base and not a cycle
+	if _, cycles := orderDeps(dp); len(cycles) != 0 {
+		t.Fatalf("Expected no cycles got %v", formatPathsPlain(cycles))
+	}
+}
@@ -187,7 +187,7 @@ func (dp *depPool) findProvides(pkgs stringSet) error {
 		words := strings.Split(pkg, "-")
 
 		for i := range words {
-			results, err = rpc.SearchByNameDesc(strings.Join(words[:i+1], "-"))
+			results, err = aurSearch("name-desc", strings.Join(words[:i+1], "-"))
 			if err == nil {
 				break
 			}
@@ -233,7 +233,14 @@ func (dp *depPool) cacheAURPackages(_pkgs stringSet) error {
 		return nil
 	}
 
-	if config.Provides {
+	if archive := loadAURArchive(); archive != nil && config.Provides {
+		// The archive knows the real providers, no need to search
+		for _, pkg := range pkgs.toSlice() {
+			for _, name := range archive.providers(pkg) {
+				pkgs.set(name)
+			}
+		}
+	} else if config.Provides {
 		err := dp.findProvides(pkgs)
 		if err != nil {
 			return err
@@ -262,7 +269,6 @@ func (dp *depPool) cacheAURPackages(_pkgs stringSet) error {
 
 func (dp *depPool) resolveAURPackages(pkgs stringSet, explicit bool) error {
 	newPackages := make(stringSet)
-	newAURPackages := make(stringSet)
 
 	err := dp.cacheAURPackages(pkgs)
 	if err != nil {
@@ -289,14 +295,22 @@ func (dp *depPool) resolveAURPackages(pkgs stringSet, explicit bool) error {
 		}
 		dp.Aur[pkg.Name] = pkg
 
-		for _, deps := range [3][]string{pkg.Depends, pkg.MakeDepends, pkg.CheckDepends} {
+		for _, deps := range buildDeps(pkg) {
 			for _, dep := range deps {
 				newPackages.set(dep)
 			}
 		}
 	}
 
-	for dep := range newPackages {
+	return dp.resolveAURDeps(newPackages)
+}
+
+// resolveAURDeps adds the dependencies of AUR packages to the pool, from the
+// repos when possible.
+func (dp *depPool) resolveAURDeps(deps stringSet) error {
+	newAURPackages := make(stringSet)
+
+	for dep := range deps {
 		if dp.hasSatisfier(dep) {
 			continue
 		}
@@ -321,8 +335,7 @@ func (dp *depPool) resolveAURPackages(pkgs stringSet, explicit bool) error {
 
 	}
 
-	err = dp.resolveAURPackages(newAURPackages, false)
-	return err
+	return dp.resolveAURPackages(newAURPackages, false)
 }
 
 func (dp *depPool) ResolveRepoDependency(pkg *alpm.Package) {
@@ -360,7 +373,11 @@ func getDepPool(pkgs []string, warnings *aurWarnings) (*depPool, error) {
 
 	dp.Warnings = warnings
 	err = dp.ResolveTargets(pkgs)
+	if err != nil {
+		return dp, err
+	}
 
+	err = dp.solve()
 	return dp, err
 }
 
@@ -409,7 +426,7 @@ func (dp *depPool) findSatisfierAurCache(dep string) *rpc.Pkg {
 	}
 
 	for _, pkg := range dp.AurCache {
-		if seen.get(pkg.Name) {
+		if seen.get(pkg.Name) || !config.Providers.allowed(depName, pkg.Name) {
 			continue
 		}
 
@@ -428,20 +445,32 @@ func (dp *depPool) findSatisfierAurCache(dep string) *rpc.Pkg {
 		}
 	}
 
-	if !config.Provides && providers.Len() >= 1 {
-		return providers.Pkgs[0]
+	if providers.Len() == 0 {
+		return nil
 	}
 
-	if providers.Len() == 1 {
-		return providers.Pkgs[0]
-	}
+	if len(config.Providers) == 0 {
+		if !config.Provides || providers.Len() == 1 {
+			return providers.Pkgs[0]
+		}
 
-	if providers.Len() > 1 {
 		sort.Sort(providers)
-		return providerMenu(dep, providers)
+		return providerMenu(dep, providers, 0)
 	}
 
-	return nil
+	// Sort first so the same provider is picked whatever the map order
+	sort.Sort(providers)
+	names := make([]string, 0, providers.Len())
+	for _, pkg := range providers.Pkgs {
+		names = append(names, pkg.Name)
+	}
+
+	index, settled := config.Providers.choose(depName, names)
+	if settled || !config.Provides {
+		return providers.Pkgs[index]
+	}
+
+	return providerMenu(dep, providers, index)
 }
 
 func (dp *depPool) findSatisfierRepo(dep string) *alpm.Package {
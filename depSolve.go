@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	alpm "github.com/Jguer/go-alpm"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// maxSolverSteps bounds the backtracking of the solver. When it is reached
+// a warning is printed and the pool is left as resolved.
+const maxSolverSteps = 100000
+
+// maxSolverRounds bounds how many times the pool is solved again after
+// packages picked by the solver brought in new dependencies.
+const maxSolverRounds = 3
+
+// solverPkg is a package the solver can pick to satisfy dependencies. source
+// is "aur", the name of a sync db or "local" for installed packages.
+type solverPkg struct {
+	name     string
+	version  string
+	source   string
+	provides []string
+	deps     []string
+
+	aur  *rpc.Pkg
+	repo *alpm.Package
+}
+
+func (pkg *solverPkg) String() string {
+	return pkg.source + "/" + pkg.name + "-" + pkg.version
+}
+
+func (pkg *solverPkg) satisfies(dep string) bool {
+	if pkgSatisfies(pkg.name, pkg.version, dep) {
+		return true
+	}
+
+	for _, provide := range pkg.provides {
+		if provideSatisfies(provide, dep) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func aurSolverPkg(pkg *rpc.Pkg) *solverPkg {
+	deps := make([]string, 0)
+	for _, list := range buildDeps(pkg) {
+		deps = append(deps, list...)
+	}
+
+	return &solverPkg{pkg.Name, pkg.Version, "aur", pkg.Provides, deps, pkg, nil}
+}
+
+// repoSolverPkg makes a solver package out of an alpm package. The
+// dependencies of installed packages are not followed, they are already
+// satisfied.
+func repoSolverPkg(pkg *alpm.Package, source string) *solverPkg {
+	deps := make([]string, 0)
+	if source != "local" {
+		deps = dependStrings(pkg.Depends())
+	}
+
+	return &solverPkg{pkg.Name(), pkg.Version(), source, dependStrings(pkg.Provides()), deps, nil, pkg}
+}
+
+// solverConstraint is a dependency the solution has to satisfy. stack is the
+// chain of packages that lead to it from a target, empty for the targets, and
+// by the dependency names those packages were picked for. db restricts where
+// the package can come from.
+type solverConstraint struct {
+	dep   string
+	db    string
+	stack []string
+	by    []string
+}
+
+func (c solverConstraint) String() string {
+	if c.db != "" {
+		return c.db + "/" + c.dep
+	}
+
+	return c.dep
+}
+
+// depSolver picks a package for every dependency name so that all the
+// constraints on that name hold at once. Candidates are tried in order and
+// the search backtracks when a later constraint rules out an earlier pick.
+type depSolver struct {
+	candidates func(name string) []*solverPkg
+	satisfies  func(pkg *solverPkg, dep string) bool
+
+	// chosen holds the package picked for each dependency name, byName the
+	// same packages by package name as only one of each can be installed.
+	chosen      map[string]*solverPkg
+	byName      map[string]*solverPkg
+	constraints map[string][]solverConstraint
+	cache       map[string][]*solverPkg
+	steps       int
+
+	// The deepest failure is the one reported
+	failedName        string
+	failedConstraints []solverConstraint
+	failedDepth       int
+}
+
+func makeDepSolver(candidates func(string) []*solverPkg, satisfies func(*solverPkg, string) bool) *depSolver {
+	return &depSolver{
+		candidates:  candidates,
+		satisfies:   satisfies,
+		chosen:      make(map[string]*solverPkg),
+		byName:      make(map[string]*solverPkg),
+		constraints: make(map[string][]solverConstraint),
+		cache:       make(map[string][]*solverPkg),
+		failedDepth: -1,
+	}
+}
+
+func (s *depSolver) candidatesOf(name string) []*solverPkg {
+	if candidates, ok := s.cache[name]; ok {
+		return candidates
+	}
+
+	candidates := s.candidates(name)
+	s.cache[name] = candidates
+	return candidates
+}
+
+func (s *depSolver) accepts(pkg *solverPkg, c solverConstraint) bool {
+	// Targets are installed even when they already are
+	if len(c.stack) == 0 && pkg.source == "local" {
+		return false
+	}
+
+	if c.db != "" && c.db != pkg.source {
+		return false
+	}
+
+	return s.satisfies(pkg, c.dep)
+}
+
+func (s *depSolver) fail(name string, depth int) {
+	if depth < s.failedDepth {
+		return
+	}
+
+	s.failedName = name
+	s.failedConstraints = append([]solverConstraint(nil), s.constraints[name]...)
+	s.failedDepth = depth
+}
+
+// gaveUp reports whether the search was cut short by maxSolverSteps.
+func (s *depSolver) gaveUp() bool {
+	return s.steps > maxSolverSteps
+}
+
+// solve picks packages satisfying the constraints in queue along with the
+// dependencies of everything picked. Constraints on a dependency nothing is
+// known to provide are left for CheckMissing to report.
+func (s *depSolver) solve(queue []solverConstraint) bool {
+	ok, _ := s.solveFrom(queue, 0)
+	return ok
+}
+
+// conflictOn returns the dependency names whose picks lead to the
+// constraints on name, along with name itself.
+func (s *depSolver) conflictOn(name string) stringSet {
+	conflict := make(stringSet)
+	conflict.set(name)
+
+	for _, c := range s.constraints[name] {
+		for _, by := range c.by {
+			conflict.set(by)
+		}
+	}
+
+	return conflict
+}
+
+// solveFrom returns the names involved when it fails. A pick that is not
+// involved can not be the cause so its other candidates are skipped and the
+// search jumps back to one that is.
+func (s *depSolver) solveFrom(queue []solverConstraint, depth int) (bool, stringSet) {
+	if len(queue) == 0 {
+		return true, nil
+	}
+
+	s.steps++
+	if s.gaveUp() {
+		return false, nil
+	}
+
+	c := queue[0]
+	name, _, _ := splitDep(c.dep)
+	s.constraints[name] = append(s.constraints[name], c)
+	defer func() {
+		s.constraints[name] = s.constraints[name][:len(s.constraints[name])-1]
+	}()
+
+	if pkg, ok := s.chosen[name]; ok {
+		if s.accepts(pkg, c) {
+			return s.solveFrom(queue[1:], depth+1)
+		}
+
+		s.fail(name, depth)
+		return false, s.conflictOn(name)
+	}
+
+	candidates := s.candidatesOf(name)
+	if len(candidates) == 0 {
+		return s.solveFrom(queue[1:], depth+1)
+	}
+
+	conflict := s.conflictOn(name)
+	for _, pkg := range candidates {
+		if other, ok := s.byName[pkg.name]; ok && other != pkg {
+			for otherName, chosen := range s.chosen {
+				if chosen == other {
+					for involved := range s.conflictOn(otherName) {
+						conflict.set(involved)
+					}
+				}
+			}
+			continue
+		}
+
+		acceptable := true
+		for _, constraint := range s.constraints[name] {
+			if !s.accepts(pkg, constraint) {
+				acceptable = false
+				break
+			}
+		}
+		if !acceptable {
+			continue
+		}
+
+		next := make([]solverConstraint, 0, len(queue)+len(pkg.deps))
+		next = append(next, queue[1:]...)
+
+		// The dependencies of a package picked for another name are queued already
+		_, picked := s.byName[pkg.name]
+		if !picked {
+			stack := append(c.stack[:len(c.stack):len(c.stack)], pkg.name)
+			by := append(c.by[:len(c.by):len(c.by)], name)
+			for _, dep := range pkg.deps {
+				next = append(next, solverConstraint{dep, "", stack, by})
+			}
+		}
+
+		s.chosen[name] = pkg
+		s.byName[pkg.name] = pkg
+		ok, involved := s.solveFrom(next, depth+1)
+		if ok {
+			return true, nil
+		}
+		delete(s.chosen, name)
+		if !picked {
+			delete(s.byName, pkg.name)
+		}
+
+		if involved == nil {
+			return false, nil
+		}
+		if !involved.get(name) {
+			return false, involved
+		}
+		for n := range involved {
+			conflict.set(n)
+		}
+	}
+
+	s.fail(name, depth)
+	conflict.remove(name)
+	return false, conflict
+}
+
+// printConflict prints the constraints that could not be satisfied together
+// and the packages that were considered for them.
+func (s *depSolver) printConflict() {
+	fmt.Println(bold(red(arrow+" Error: ")) + "Could not satisfy all version constraints on " + cyan(s.failedName) + ":")
+	for _, c := range s.failedConstraints {
+		printWantedBy(cyan(c.String()), c.stack)
+	}
+
+	candidates := s.candidatesOf(s.failedName)
+	if len(candidates) == 0 {
+		return
+	}
+
+	fmt.Print("    ", bold("Available:"))
+	for _, pkg := range candidates {
+		fmt.Print(" ", pkg)
+	}
+	fmt.Println()
+}
+
+// solverCandidates returns the packages that may satisfy a dependency on
+// name, in the order the solver should try them: packages already in the
+// pool, the installed package, then the repos and the AUR. known makes sure
+// the same package is always the same solverPkg.
+func (dp *depPool) solverCandidates(name string, known map[string]*solverPkg) []*solverPkg {
+	candidates := make([]*solverPkg, 0)
+	add := func(pkg *solverPkg) {
+		key := pkg.source + "/" + pkg.name
+		if prev, ok := known[key]; ok {
+			for _, candidate := range candidates {
+				if candidate == prev {
+					return
+				}
+			}
+			pkg = prev
+		} else {
+			known[key] = pkg
+		}
+
+		candidates = append(candidates, pkg)
+	}
+
+	pool := make([]*solverPkg, 0)
+	for _, pkg := range dp.Aur {
+		if satisfiesAur(name, pkg) {
+			pool = append(pool, aurSolverPkg(pkg))
+		}
+	}
+	for _, pkg := range dp.Repo {
+		if satisfiesRepo(name, pkg) {
+			pool = append(pool, repoSolverPkg(pkg, pkg.DB().Name()))
+		}
+	}
+	sort.Slice(pool, func(i, j int) bool {
+		if (pool[i].name == name) != (pool[j].name == name) {
+			return pool[i].name == name
+		}
+		if pool[i].source != pool[j].source {
+			return pool[i].source == "aur"
+		}
+		return pool[i].name < pool[j].name
+	})
+	for _, pkg := range pool {
+		add(pkg)
+	}
+
+	if pkg, err := dp.LocalDB.PkgCache().FindSatisfier(name); err == nil {
+		add(repoSolverPkg(pkg, "local"))
+	}
+
+	dp.SyncDB.ForEach(func(db alpm.DB) error {
+		if pkg, err := db.PkgCache().FindSatisfier(name); err == nil {
+			add(repoSolverPkg(pkg, db.Name()))
+		}
+		return nil
+	})
+
+	if mode == modeRepo {
+		return candidates
+	}
+
+	providers := makeProviders(name)
+	for _, pkg := range dp.AurCache {
+		if satisfiesAur(name, pkg) && config.Providers.allowed(name, pkg.Name) {
+			providers.Pkgs = append(providers.Pkgs, pkg)
+		}
+	}
+	sort.Sort(providers)
+
+	names := make([]string, 0, providers.Len())
+	for _, pkg := range providers.Pkgs {
+		names = append(names, pkg.Name)
+	}
+	if index, _ := config.Providers.choose(name, names); index > 0 {
+		providers.Swap(0, index)
+	}
+
+	for _, pkg := range providers.Pkgs {
+		add(aurSolverPkg(pkg))
+	}
+
+	return candidates
+}
+
+// solve checks that the packages in the pool satisfy every version
+// constraint at once and swaps packages when they do not. This catches a
+// dependency resolved to a package a later dependency rules out, which would
+// otherwise only fail once pacman installs the packages. The solver only
+// runs when a dependency with a version constraint is not satisfied.
+func (dp *depPool) solve() error {
+	if !dp.findMissing().versionConstraints() {
+		return nil
+	}
+
+	roots := make([]solverConstraint, 0, len(dp.Targets))
+	for _, target := range dp.Targets {
+		roots = append(roots, solverConstraint{target.DepString(), target.DB, nil, nil})
+	}
+
+	for round := 0; round < maxSolverRounds; round++ {
+		known := make(map[string]*solverPkg)
+		candidates := func(name string) []*solverPkg {
+			return dp.solverCandidates(name, known)
+		}
+
+		s := makeDepSolver(candidates, (*solverPkg).satisfies)
+		ok := s.solve(roots)
+		if s.gaveUp() {
+			fmt.Fprintln(os.Stderr, bold(yellow(arrow+" Warning: "))+
+				fmt.Sprintf("Gave up solving version constraints after %d steps, the dependencies are left as resolved", maxSolverSteps))
+			return nil
+		}
+		if !ok {
+			s.printConflict()
+			return fmt.Errorf("")
+		}
+
+		changed, err := dp.applySolution(s)
+		if err != nil || !changed {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applySolution makes the pool hold the packages picked by the solver. It
+// returns whether the pool changed.
+func (dp *depPool) applySolution(s *depSolver) (bool, error) {
+	changed := false
+
+	replacement := func(name string, provides []string) *solverPkg {
+		if pkg, ok := s.chosen[name]; ok {
+			return pkg
+		}
+		for _, provide := range provides {
+			provideName, _, _ := splitDep(provide)
+			if pkg, ok := s.chosen[provideName]; ok {
+				return pkg
+			}
+		}
+		return nil
+	}
+
+	drop := func(old string, name string, provides []string) {
+		changed = true
+		dp.Explicit.remove(name)
+
+		if pkg := replacement(name, provides); pkg != nil {
+			fmt.Fprintln(os.Stderr, bold(cyan("::")), bold("Using"), cyan(pkg.String()), bold("instead of"), cyan(old))
+		}
+	}
+
+	for name, pkg := range dp.Aur {
+		if picked, ok := s.byName[name]; !ok || picked.aur != pkg {
+			delete(dp.Aur, name)
+			drop("aur/"+name+"-"+pkg.Version, name, pkg.Provides)
+		}
+	}
+
+	for name, pkg := range dp.Repo {
+		if picked, ok := s.byName[name]; !ok || picked.source != pkg.DB().Name() {
+			delete(dp.Repo, name)
+			drop(pkg.DB().Name()+"/"+name+"-"+pkg.Version(), name, dependStrings(pkg.Provides()))
+		}
+	}
+
+	names := make([]string, 0, len(s.byName))
+	for name := range s.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	aurDeps := make(stringSet)
+	for _, name := range names {
+		pkg := s.byName[name]
+
+		switch {
+		case pkg.aur != nil:
+			if _, ok := dp.Aur[name]; !ok {
+				dp.Aur[name] = pkg.aur
+				for _, dep := range pkg.deps {
+					aurDeps.set(dep)
+				}
+				changed = true
+			}
+		case pkg.repo != nil && pkg.source != "local":
+			if _, ok := dp.Repo[name]; !ok {
+				dp.ResolveRepoDependency(pkg.repo)
+				changed = true
+			}
+		}
+	}
+
+	for _, target := range dp.Targets {
+		if pkg, ok := s.chosen[target.Name]; ok && pkg.source != "local" {
+			dp.Explicit.set(pkg.name)
+		}
+	}
+
+	if len(aurDeps) > 0 {
+		return changed, dp.resolveAURDeps(aurDeps)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testSatisfies is solverPkg.satisfies for integer versions, without alpm.
+func testSatisfies(pkg *solverPkg, dep string) bool {
+	cmp := func(version, mod, want string) bool {
+		if mod == "" {
+			return true
+		}
+
+		a, _ := strconv.Atoi(version)
+		b, _ := strconv.Atoi(want)
+		switch mod {
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		}
+		return a == b
+	}
+
+	name, mod, version := splitDep(dep)
+	if pkg.name == name && cmp(pkg.version, mod, version) {
+		return true
+	}
+
+	for _, provide := range pkg.provides {
+		provideName, _, provideVersion := splitDep(provide)
+		if provideName == name && cmp(provideVersion, mod, version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testSolver(pkgs ...*solverPkg) *depSolver {
+	candidates := func(name string) []*solverPkg {
+		found := make([]*solverPkg, 0)
+		for _, pkg := range pkgs {
+			if testSatisfies(pkg, name) {
+				found = append(found, pkg)
+			}
+		}
+		return found
+	}
+
+	return makeDepSolver(candidates, testSatisfies)
+}
+
+func testRoots(deps ...string) []solverConstraint {
+	roots := make([]solverConstraint, 0, len(deps))
+	for _, dep := range deps {
+		roots = append(roots, solverConstraint{dep, "", nil, nil})
+	}
+	return roots
+}
+
+func solution(s *depSolver) string {
+	picked := make([]string, 0, len(s.byName))
+	for _, pkg := range s.byName {
+		picked = append(picked, pkg.String())
+	}
+	sort.Strings(picked)
+	return strings.Join(picked, " ")
+}
+
+func TestSolverConstraints(t *testing.T) {
+	s := testSolver(
+		&solverPkg{name: "a", version: "1", source: "aur", deps: []string{"foo>=2"}},
+		&solverPkg{name: "b", version: "1", source: "aur", deps: []string{"foo<3"}},
+		&solverPkg{name: "foo", version: "1", source: "extra"},
+		&solverPkg{name: "foo", version: "2", source: "aur"},
+	)
+
+	if !s.solve(testRoots("b", "a")) {
+		t.Fatal("Expected a solution")
+	}
+	if expected := "aur/a-1 aur/b-1 aur/foo-2"; solution(s) != expected {
+		t.Fatalf("Expected %s got %s", expected, solution(s))
+	}
+}
+
+func TestSolverBacktrack(t *testing.T) {
+	s := testSolver(
+		&solverPkg{name: "app", version: "1", source: "aur", deps: []string{"lib", "tool"}},
+		&solverPkg{name: "lib-git", version: "5", source: "aur", provides: []string{"lib=5"}, deps: []string{"foo<2"}},
+		&solverPkg{name: "lib", version: "4", source: "extra", deps: []string{"foo"}},
+		&solverPkg{name: "tool", version: "1", source: "extra", deps: []string{"foo>=2"}},
+		&solverPkg{name: "foo", version: "1", source: "local"},
+		&solverPkg{name: "foo", version: "2", source: "extra"},
+	)
+
+	if !s.solve(testRoots("app")) {
+		t.Fatal("Expected a solution")
+	}
+	if expected := "aur/app-1 extra/foo-2 extra/lib-4 extra/tool-1"; solution(s) != expected {
+		t.Fatalf("Expected %s got %s", expected, solution(s))
+	}
+}
+
+func TestSolverTargets(t *testing.T) {
+	s := testSolver(
+		&solverPkg{name: "foo", version: "1", source: "local"},
+		&solverPkg{name: "foo", version: "1", source: "extra"},
+		&solverPkg{name: "foo", version: "2", source: "aur"},
+	)
+
+	// Targets are never satisfied by the installed package and respect db/
+	roots := testRoots("foo")
+	roots[0].db = "aur"
+	if !s.solve(roots) {
+		t.Fatal("Expected a solution")
+	}
+	if expected := "aur/foo-2"; solution(s) != expected {
+		t.Fatalf("Expected %s got %s", expected, solution(s))
+	}
+}
+
+func TestSolverConflict(t *testing.T) {
+	s := testSolver(
+		&solverPkg{name: "a", version: "1", source: "aur", deps: []string{"foo>=2"}},
+		&solverPkg{name: "b", version: "1", source: "aur", deps: []string{"bar"}},
+		&solverPkg{name: "bar", version: "1", source: "aur", deps: []string{"foo<2"}},
+		&solverPkg{name: "foo", version: "1", source: "extra"},
+		&solverPkg{name: "foo", version: "2", source: "aur"},
+		&solverPkg{name: "missing", version: "1", source: "aur", deps: []string{"nothing>=1"}},
+	)
+
+	if s.solve(testRoots("a", "b", "missing")) {
+		t.Fatalf("Expected no solution got %s", solution(s))
+	}
+
+	if s.failedName != "foo" || len(s.failedConstraints) != 2 {
+		t.Fatalf("Expected a conflict on foo got %s %v", s.failedName, s.failedConstraints)
+	}
+
+	chains := make([]string, 0, 2)
+	for _, c := range s.failedConstraints {
+		chains = append(chains, c.dep+" "+strings.Join(c.stack, "->"))
+	}
+	if expected := "foo>=2 a, foo<2 b->bar"; strings.Join(chains, ", ") != expected {
+		t.Fatalf("Expected %s got %s", expected, strings.Join(chains, ", "))
+	}
+}
+
+func TestMissingVersionConstraints(t *testing.T) {
+	m := &missing{Missing: map[string][][]string{"foo": nil}}
+	if m.versionConstraints() {
+		t.Error("a missing package without a version is not a version constraint")
+	}
+
+	m.Missing["bar>=2"] = nil
+	if !m.versionConstraints() {
+		t.Error("bar>=2 should be reported as a version constraint")
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// pkgbuildRevision is a commit of an AUR base that changed its .SRCINFO.
+type pkgbuildRevision struct {
+	hash    string
+	time    int
+	subject string
+	version string
+}
+
+// downgradeDir holds the worktrees that old revisions are built in. It is
+// kept apart from BuildDir so the clones there are never moved off master.
+func downgradeDir() string {
+	return filepath.Join(cacheHome, "downgrade")
+}
+
+// downgradeBase returns the package base of name, looking at the installed
+// package first as it may no longer be in the AUR.
+func downgradeBase(name string) (string, error) {
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return "", err
+	}
+
+	if pkg := localDB.Pkg(name); pkg != nil && pkg.Base() != "" {
+		return pkg.Base(), nil
+	}
+
+	info, err := aurInfoPrint([]string{name})
+	if err != nil {
+		return "", err
+	}
+	if len(info) == 0 {
+		return "", fmt.Errorf("%s is not installed and is not in the AUR", name)
+	}
+
+	return info[0].PackageBase, nil
+}
+
+// pkgbuildRevisions lists the revisions of the base cloned in dir, newest
+// first. The upstream branch is listed as the clone is only fetched, not
+// merged.
+func pkgbuildRevisions(dir string) ([]pkgbuildRevision, error) {
+	stdout, stderr, err := capture(passToGit(dir, "log", "--format=%H%x09%ct%x09%s", "HEAD@{upstream}", "--", ".SRCINFO"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading history of %s: %s", dir, stderr)
+	}
+
+	revisions := make([]pkgbuildRevision, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		split := strings.SplitN(line, "\t", 3)
+		if len(split) != 3 {
+			continue
+		}
+
+		time, _ := strconv.Atoi(split[1])
+		srcinfo, err := revisionSrcinfo(dir, split[0])
+		if err != nil {
+			continue
+		}
+
+		revisions = append(revisions, pkgbuildRevision{split[0], time, split[2], srcinfo.Version()})
+	}
+
+	return revisions, nil
+}
+
+func revisionSrcinfo(dir string, hash string) (*gosrc.Srcinfo, error) {
+	stdout, stderr, err := capture(passToGit(dir, "show", hash+":.SRCINFO"))
+	if err != nil {
+		return nil, fmt.Errorf("%s%s", stderr, err)
+	}
+
+	return gosrc.Parse(stdout)
+}
+
+// chooseRevision shows the revisions of pkgbase and asks which one to build.
+func chooseRevision(pkgbase string, revisions []pkgbuildRevision, installed string) (*pkgbuildRevision, error) {
+	for n := len(revisions) - 1; n >= 0; n-- {
+		rev := revisions[n]
+		version := rev.version
+		if version == installed {
+			version = green(version + " (Installed)")
+		}
+
+		fmt.Printf("%s %s %s %s %s\n", magenta(strconv.Itoa(n+1)), bold(pkgbase), version,
+			formatTime(rev.time), rev.subject)
+	}
+
+	fmt.Println(bold(green(arrow + " Revision to build (eg: 2)")))
+	fmt.Print(bold(green(arrow + " ")))
+	input, err := getInput("")
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || n < 1 || n > len(revisions) {
+		return nil, fmt.Errorf("no revision selected")
+	}
+
+	return &revisions[n-1], nil
+}
+
+// installedRevision returns the newest of revisions that builds version, or
+// the empty tree when there is none so a diff shows the whole PKGBUILD.
+func installedRevision(revisions []pkgbuildRevision, version string) string {
+	for _, rev := range revisions {
+		if rev.version == version {
+			return rev.hash
+		}
+	}
+
+	return gitEmptyTree
+}
+
+// showRevisionDiff shows the changes to the base cloned in clone from one
+// revision to another.
+func showRevisionDiff(clone string, from string, to string) error {
+	args := []string{"diff", from + ".." + to, "--src-prefix", clone + "/", "--dst-prefix", clone + "/", "--", ".", ":(exclude).SRCINFO"}
+	if useColor {
+		args = append(args, "--color=always")
+	} else {
+		args = append(args, "--color=never")
+	}
+
+	return show(passToGit(clone, args...))
+}
+
+// checkoutRevision checks out hash of the base cloned in clone into a detached
+// worktree in downgradeDir, replacing any worktree left there.
+func checkoutRevision(clone string, pkgbase string, hash string) error {
+	dir := filepath.Join(downgradeDir(), pkgbase)
+
+	if err := os.MkdirAll(downgradeDir(), 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	_, stderr, err := captureLog(passToGit(clone, "worktree", "prune"), pkgbase)
+	if err != nil {
+		return fmt.Errorf("error pruning worktrees of %s: %s", pkgbase, stderr)
+	}
+
+	_, stderr, err = captureLog(passToGit(clone, "worktree", "add", "--detach", "--force", dir, hash), pkgbase)
+	if err != nil {
+		return fmt.Errorf("error checking out %s: %s", pkgbase, stderr)
+	}
+
+	return nil
+}
+
+// srcinfoToBase describes the packages of srcinfo the way the AUR RPC would,
+// keeping only the dependencies that apply to arch.
+func srcinfoToBase(srcinfo *gosrc.Srcinfo, arch string) Base {
+	values := func(strs []gosrc.ArchString) []string {
+		out := make([]string, 0, len(strs))
+		for _, str := range strs {
+			if str.Arch == "" || str.Arch == arch {
+				out = append(out, str.Value)
+			}
+		}
+		return out
+	}
+
+	base := make(Base, 0, len(srcinfo.Packages))
+	for _, split := range srcinfo.SplitPackages() {
+		base = append(base, &rpc.Pkg{
+			Name:         split.Pkgname,
+			PackageBase:  srcinfo.Pkgbase,
+			Version:      srcinfo.Version(),
+			Description:  split.Pkgdesc,
+			URL:          split.URL,
+			Depends:      values(split.Depends),
+			MakeDepends:  values(srcinfo.MakeDepends),
+			CheckDepends: values(srcinfo.CheckDepends),
+			Conflicts:    values(split.Conflicts),
+			Provides:     values(split.Provides),
+			Replaces:     values(split.Replaces),
+			OptDepends:   values(split.OptDepends),
+			Groups:       split.Groups,
+			License:      split.License,
+		})
+	}
+
+	return base
+}
+
+// downgrade builds and installs an older revision of the AUR package name
+// picked from the git history of its base.
+func downgrade(name string) error {
+	if os.Geteuid() == 0 {
+		return fmt.Errorf("%s Refusing to install AUR Packages as root, Aborting.", bold(red(arrow)))
+	}
+
+	pkgbase, err := downgradeBase(name)
+	if err != nil {
+		return err
+	}
+
+	defer startBuildLogs()()
+	defer startHistory()()
+
+	clone := filepath.Join(config.BuildDir, pkgbase)
+	if !shouldUseGit(clone) {
+		return fmt.Errorf("%s was not downloaded with git, remove %s to clone it", pkgbase, clone)
+	}
+	if _, err = gitDownload(config.AURURL+"/"+pkgbase+".git", config.BuildDir, pkgbase); err != nil {
+		return err
+	}
+
+	revisions, err := pkgbuildRevisions(clone)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return fmt.Errorf("no revisions of %s found", pkgbase)
+	}
+
+	dp, err := makeDepPool()
+	if err != nil {
+		return err
+	}
+
+	installed := ""
+	if pkg := dp.LocalDB.Pkg(name); pkg != nil {
+		installed = pkg.Version()
+	}
+
+	rev, err := chooseRevision(pkgbase, revisions, installed)
+	if err != nil {
+		return err
+	}
+
+	if err = checkoutRevision(clone, pkgbase, rev.hash); err != nil {
+		return err
+	}
+
+	dir := downgradeDir()
+
+	arch, err := alpmHandle.Arch()
+	if err != nil {
+		return err
+	}
+
+	srcinfo, err := gosrc.ParseFile(filepath.Join(dir, pkgbase, ".SRCINFO"))
+	if err != nil {
+		return fmt.Errorf("%s: %s", pkgbase, err)
+	}
+	setBaseChecks(pkgbase, srcinfo)
+	base := srcinfoToBase(srcinfo, arch)
+
+	if err = installDowngradeDeps(dp, base); err != nil {
+		return err
+	}
+
+	// Installing the dependencies opened a new handle
+	if dp, err = makeDepPool(); err != nil {
+		return err
+	}
+	for _, pkg := range base {
+		dp.Explicit.set(pkg.Name)
+	}
+
+	do := makeDepOrder()
+	do.Aur = append(do.Aur, base)
+	srcinfos := map[string]*gosrc.Srcinfo{pkgbase: srcinfo}
+	bases := []Base{base}
+
+	remoteNamesCache := make(stringSet)
+	if config.DiffMenu || config.EditMenu {
+		_, _, _, remoteNames, err := filterPackages()
+		if err != nil {
+			return err
+		}
+		remoteNamesCache = sliceToStringSet(remoteNames)
+	}
+
+	// The diff goes from the installed revision to the one to build
+	if config.DiffMenu {
+		pkgbuildNumberMenu(dir, bases, remoteNamesCache)
+		toDiff, err := diffNumberMenu(bases, remoteNamesCache)
+		if err != nil {
+			return err
+		}
+
+		if len(toDiff) > 0 {
+			if err = showRevisionDiff(clone, installedRevision(revisions, installed), rev.hash); err != nil {
+				return err
+			}
+
+			oldValue := config.NoConfirm
+			config.NoConfirm = false
+			fmt.Println()
+			if !continueTask(bold(green("Proceed with install?")), true) {
+				return fmt.Errorf("Aborting due to user")
+			}
+			config.NoConfirm = oldValue
+		}
+	}
+
+	if config.EditMenu {
+		pkgbuildNumberMenu(dir, bases, remoteNamesCache)
+		toEdit, err := editNumberMenu(bases, remoteNamesCache)
+		if err != nil {
+			return err
+		}
+
+		if len(toEdit) > 0 {
+			if err = editPkgbuilds(dir, toEdit, srcinfos); err != nil {
+				return err
+			}
+			if config.SrcinfoDeps {
+				if err = regenerateSrcinfos(dir, srcinfos, toEdit); err != nil {
+					return err
+				}
+			}
+
+			oldValue := config.NoConfirm
+			config.NoConfirm = false
+			fmt.Println()
+			if !continueTask(bold(green("Proceed with install?")), true) {
+				return fmt.Errorf("Aborting due to user")
+			}
+			config.NoConfirm = oldValue
+		}
+	}
+
+	incompatible, err := getIncompatible(bases, srcinfos)
+	if err != nil {
+		return err
+	}
+
+	if config.PGPFetch {
+		if err = checkPgpKeys(bases, srcinfos); err != nil {
+			return err
+		}
+	}
+
+	if err = downloadPkgbuildsSources(dir, bases, incompatible); err != nil {
+		return err
+	}
+
+	parser := cmdArgs.copy()
+	parser.delArg("downgrade")
+	err = buildInstallPkgbuilds(dir, dp, do, srcinfos, parser, incompatible, make(mapStringSet), nil)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(base))
+	for _, pkg := range base {
+		names = append(names, pkg.Name)
+	}
+
+	question := fmt.Sprintf("Add %s to IgnorePkg in %s?", strings.Join(names, ", "), config.PacmanConf)
+	if continueTask(question, false) {
+		return addIgnorePkg(names)
+	}
+
+	return nil
+}
+
+// installDowngradeDeps installs the dependencies needed to build base that
+// are not installed, resolving them from the repos and the AUR like -S would.
+func installDowngradeDeps(dp *depPool, base Base) error {
+	inBase := func(dep string) bool {
+		for _, pkg := range base {
+			if satisfiesAur(dep, pkg) {
+				return true
+			}
+		}
+		return false
+	}
+
+	missing := make([]string, 0)
+	seen := make(stringSet)
+	for _, pkg := range base {
+		for _, deps := range buildDeps(pkg) {
+			for _, dep := range deps {
+				if seen.get(dep) || inBase(dep) {
+					continue
+				}
+				seen.set(dep)
+
+				if _, err := dp.LocalDB.PkgCache().FindSatisfier(dep); err != nil {
+					missing = append(missing, dep)
+				}
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	fmt.Println(bold(cyan("::")), bold(base.Pkgbase()+" needs packages which are not installed:"))
+	for _, dep := range missing {
+		fmt.Println("   ", cyan(dep))
+	}
+
+	arguments := cmdArgs.copyGlobal()
+	arguments.op = "S"
+	arguments.addArg("asdeps")
+	arguments.addTarget(missing...)
+	if err := install(arguments); err != nil {
+		return err
+	}
+
+	return initAlpmHandle()
+}
+
+// ignorePkgConf returns conf with names added to the IgnorePkg line of its
+// [options] section, creating the line when there is none. ok is false when
+// conf has no [options] section.
+func ignorePkgConf(conf string, names []string) (string, bool) {
+	lines := strings.Split(conf, "\n")
+	section := ""
+	options := -1
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = trimmed[1 : len(trimmed)-1]
+			if section == "options" {
+				options = i
+			}
+			continue
+		}
+
+		split := strings.SplitN(trimmed, "=", 2)
+		if section == "options" && len(split) == 2 && strings.TrimSpace(split[0]) == "IgnorePkg" {
+			lines[i] = line + " " + strings.Join(names, " ")
+			return strings.Join(lines, "\n"), true
+		}
+	}
+
+	if options == -1 {
+		return conf, false
+	}
+
+	line := "IgnorePkg = " + strings.Join(names, " ")
+	lines = append(lines[:options+1], append([]string{line}, lines[options+1:]...)...)
+	return strings.Join(lines, "\n"), true
+}
+
+// addIgnorePkg adds names to IgnorePkg in pacman.conf, writing it through
+// sudo.
+func addIgnorePkg(names []string) error {
+	data, err := ioutil.ReadFile(config.PacmanConf)
+	if err != nil {
+		return err
+	}
+
+	conf, ok := ignorePkgConf(string(data), names)
+	if !ok {
+		return fmt.Errorf("no [options] section in %s", config.PacmanConf)
+	}
+
+	tmp, err := ioutil.TempFile("", "pacman.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(conf)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = show(exec.Command("sudo", "install", "-m644", tmp.Name(), config.PacmanConf))
+	if err != nil {
+		return fmt.Errorf("Error writing %s", config.PacmanConf)
+	}
+
+	return nil
+}
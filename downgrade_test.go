@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+func TestSrcinfoToBase(t *testing.T) {
+	srcinfo, err := gosrc.Parse(`pkgbase = foo
+	pkgver = 1.0
+	pkgrel = 2
+	epoch = 1
+	arch = x86_64
+	arch = i686
+	checkdepends = python
+	makedepends = cmake
+	makedepends_i686 = gcc-multilib
+	depends = glibc
+	depends_x86_64 = lib64
+	provides = foo-api=1
+
+pkgname = foo
+
+pkgname = foo-docs
+	depends =
+	depends_i686 = docs-i686
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := srcinfoToBase(srcinfo, "x86_64")
+	if len(base) != 2 || base.Pkgbase() != "foo" || base.Version() != "1:1.0-2" {
+		t.Fatalf("expected foo and foo-docs at 1:1.0-2 got %v", base)
+	}
+
+	foo, docs := base[0], base[1]
+	if strings.Join(foo.Depends, " ") != "glibc lib64" || strings.Join(foo.MakeDepends, " ") != "cmake" {
+		t.Errorf("unexpected x86_64 dependencies of foo %v %v", foo.Depends, foo.MakeDepends)
+	}
+	if strings.Join(foo.CheckDepends, " ") != "python" || strings.Join(foo.Provides, " ") != "foo-api=1" {
+		t.Errorf("unexpected checkdepends or provides of foo %v %v", foo.CheckDepends, foo.Provides)
+	}
+	// Overriding depends leaves the depends_x86_64 of pkgbase in place
+	if strings.Join(docs.Depends, " ") != "lib64" || strings.Join(docs.MakeDepends, " ") != "cmake" {
+		t.Errorf("unexpected x86_64 dependencies of foo-docs %v %v", docs.Depends, docs.MakeDepends)
+	}
+
+	base = srcinfoToBase(srcinfo, "i686")
+	if strings.Join(base[0].Depends, " ") != "glibc" || strings.Join(base[0].MakeDepends, " ") != "cmake gcc-multilib" {
+		t.Errorf("unexpected i686 dependencies of foo %v %v", base[0].Depends, base[0].MakeDepends)
+	}
+	if strings.Join(base[1].Depends, " ") != "docs-i686" {
+		t.Errorf("unexpected i686 dependencies of foo-docs %v", base[1].Depends)
+	}
+}
+
+func TestIgnorePkgConf(t *testing.T) {
+	tests := []struct {
+		conf     string
+		expected string
+		ok       bool
+	}{
+		{
+			"[options]\nHoldPkg = pacman\nIgnorePkg = bar\n\n[core]\nInclude = mirrorlist\n",
+			"[options]\nHoldPkg = pacman\nIgnorePkg = bar foo foo-docs\n\n[core]\nInclude = mirrorlist\n",
+			true,
+		},
+		{
+			"[options]\n#IgnorePkg =\nArchitecture = auto\n\n[core]\nIgnorePkg = other\n",
+			"[options]\nIgnorePkg = foo foo-docs\n#IgnorePkg =\nArchitecture = auto\n\n[core]\nIgnorePkg = other\n",
+			true,
+		},
+		{
+			"  [options]  \n  IgnorePkg=bar\n",
+			"  [options]  \n  IgnorePkg=bar foo foo-docs\n",
+			true,
+		},
+		{
+			"[core]\nIgnorePkg = bar\n",
+			"[core]\nIgnorePkg = bar\n",
+			false,
+		},
+	}
+
+	for n, test := range tests {
+		conf, ok := ignorePkgConf(test.conf, []string{"foo", "foo-docs"})
+		if conf != test.expected || ok != test.ok {
+			t.Errorf("Test %d Failed: Expected %q %t got %q %t", n+1, test.expected, test.ok, conf, ok)
+		}
+	}
+}
+
+func TestInstalledRevision(t *testing.T) {
+	revisions := []pkgbuildRevision{
+		{hash: "c", version: "1.1-1"},
+		{hash: "b", version: "1.0-1"},
+		{hash: "a", version: "1.0-1"},
+	}
+
+	if hash := installedRevision(revisions, "1.0-1"); hash != "b" {
+		t.Errorf("expected the newest revision of 1.0-1 got %s", hash)
+	}
+	if hash := installedRevision(revisions, "0.9-1"); hash != gitEmptyTree {
+		t.Errorf("expected the empty tree got %s", hash)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -36,7 +35,7 @@ func downloadFile(path string, url string) (err error) {
 	defer out.Close()
 
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := httpGet(url)
 	if err != nil {
 		return err
 	}
@@ -65,7 +64,7 @@ func gitDownload(url string, path string, name string) (bool, error) {
 	if os.IsNotExist(err) {
 		cmd := passToGit(path, "clone", "--no-progress", url, name)
 		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
-		_, stderr, err := capture(cmd)
+		_, stderr, err := captureLog(cmd, name)
 		if err != nil {
 			return false, fmt.Errorf("error cloning %s: %s", name, stderr)
 		}
@@ -77,7 +76,7 @@ func gitDownload(url string, path string, name string) (bool, error) {
 
 	cmd := passToGit(filepath.Join(path, name), "fetch")
 	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
-	_, stderr, err := capture(cmd)
+	_, stderr, err := captureLog(cmd, name)
 	if err != nil {
 		return false, fmt.Errorf("error fetching %s: %s", name, stderr)
 	}
@@ -86,12 +85,12 @@ func gitDownload(url string, path string, name string) (bool, error) {
 }
 
 func gitMerge(path string, name string) error {
-	_, stderr, err := capture(passToGit(filepath.Join(path, name), "reset", "--hard", "HEAD"))
+	_, stderr, err := captureLog(passToGit(filepath.Join(path, name), "reset", "--hard", "HEAD"), name)
 	if err != nil {
 		return fmt.Errorf("error resetting %s: %s", name, stderr)
 	}
 
-	_, stderr, err = capture(passToGit(filepath.Join(path, name), "merge", "--no-edit", "--ff"))
+	_, stderr, err = captureLog(passToGit(filepath.Join(path, name), "merge", "--no-edit", "--ff"), name)
 	if err != nil {
 		return fmt.Errorf("error merging %s: %s", name, stderr)
 	}
@@ -125,6 +124,9 @@ func downloadAndUnpack(url string, path string) error {
 }
 
 func getPkgbuilds(pkgs []string) error {
+	out, restore := redirectStdout()
+	defer restore()
+
 	missing := false
 	wd, err := os.Getwd()
 	if err != nil {
@@ -148,8 +150,9 @@ func getPkgbuilds(pkgs []string) error {
 		return err
 	}
 
+	var fetched []formatPkg
 	if len(repo) > 0 {
-		missing, err = getPkgbuildsfromABS(repo, wd)
+		missing, fetched, err = getPkgbuildsfromABS(repo, wd)
 		if err != nil {
 			return err
 		}
@@ -183,9 +186,26 @@ func getPkgbuilds(pkgs []string) error {
 			return err
 		}
 
+		for _, base := range bases {
+			for _, pkg := range base {
+				record := aurFormatPkg(pkg)
+				record.Path = filepath.Join(wd, base.Pkgbase())
+				fetched = append(fetched, record)
+			}
+		}
+
 		missing = missing || len(aur) != len(info)
 	}
 
+	if config.Format != "" {
+		if fetched == nil {
+			fetched = make([]formatPkg, 0)
+		}
+		if err = printFormatted(out, config.Format, fetched); err != nil {
+			return err
+		}
+	}
+
 	if missing {
 		err = fmt.Errorf("")
 	}
@@ -193,18 +213,21 @@ func getPkgbuilds(pkgs []string) error {
 	return err
 }
 
-// GetPkgbuild downloads pkgbuild from the ABS.
-func getPkgbuildsfromABS(pkgs []string, path string) (bool, error) {
+// GetPkgbuild downloads pkgbuild from the ABS. It returns the packages whose
+// PKGBUILDs were downloaded.
+func getPkgbuildsfromABS(pkgs []string, path string) (bool, []formatPkg, error) {
 	var wg sync.WaitGroup
 	var mux sync.Mutex
 	var errs MultiError
 	names := make(map[string]string)
+	fromBase := make(map[string]*alpm.Package)
+	fetched := make([]formatPkg, 0)
 	missing := make([]string, 0)
 	downloaded := 0
 
 	dbList, err := alpmHandle.SyncDBs()
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
 
 	for _, pkgN := range pkgs {
@@ -262,6 +285,7 @@ func getPkgbuildsfromABS(pkgs []string, path string) (bool, error) {
 		}
 
 		names[name] = url
+		fromBase[name] = pkg
 	}
 
 	if len(missing) != 0 {
@@ -282,6 +306,9 @@ func getPkgbuildsfromABS(pkgs []string, path string) (bool, error) {
 			errs.Add(fmt.Errorf("%s Failed to move %s: %s", bold(red(arrow)), bold(cyan(pkg)), bold(red(stderr))))
 		} else {
 			fmt.Printf(bold(cyan("::"))+" Downloaded PKGBUILD from ABS (%d/%d): %s\n", downloaded, len(names), cyan(pkg))
+			record := repoFormatPkg(fromBase[pkg])
+			record.Path = filepath.Join(path, pkg)
+			fetched = append(fetched, record)
 		}
 		mux.Unlock()
 	}
@@ -298,5 +325,5 @@ func getPkgbuildsfromABS(pkgs []string, path string) (bool, error) {
 
 	wg.Wait()
 	errs.Add(os.RemoveAll(filepath.Join(cacheHome, "packages")))
-	return len(missing) != 0, errs.Return()
+	return len(missing) != 0, fetched, errs.Return()
 }
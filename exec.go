@@ -110,6 +110,21 @@ func passToMakepkg(dir string, args ...string) *exec.Cmd {
 	return cmd
 }
 
+func passToMakechrootpkg(dir string, chroot string, copyName string, deps []string, args ...string) *exec.Cmd {
+	argArr := []string{"-c", "-r", chroot, "-l", copyName}
+	for _, dep := range deps {
+		argArr = append(argArr, "-I", dep)
+	}
+
+	argArr = append(argArr, "--")
+	argArr = append(argArr, args...)
+	argArr = append(argArr, strings.Fields(config.MFlags)...)
+
+	cmd := exec.Command("makechrootpkg", argArr...)
+	cmd.Dir = dir
+	return cmd
+}
+
 func passToGit(dir string, _args ...string) *exec.Cmd {
 	gitflags := strings.Fields(config.GitFlags)
 	args := []string{"-C", dir}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	alpm "github.com/Jguer/go-alpm"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// formatPkg is a package as --format prints it, whether it comes from a
+// repo, the AUR or the local database. Source is repo, aur or local.
+type formatPkg struct {
+	Source        string   `json:"source"`
+	Repository    string   `json:"repository"`
+	Name          string   `json:"name"`
+	Base          string   `json:"base,omitempty"`
+	Version       string   `json:"version"`
+	Installed     string   `json:"installed,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	URL           string   `json:"url,omitempty"`
+	Licenses      []string `json:"licenses,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	Provides      []string `json:"provides,omitempty"`
+	Depends       []string `json:"depends,omitempty"`
+	MakeDepends   []string `json:"makedepends,omitempty"`
+	CheckDepends  []string `json:"checkdepends,omitempty"`
+	OptDepends    []string `json:"optdepends,omitempty"`
+	Conflicts     []string `json:"conflicts,omitempty"`
+	Replaces      []string `json:"replaces,omitempty"`
+	Maintainer    string   `json:"maintainer,omitempty"`
+	Votes         int      `json:"votes,omitempty"`
+	Popularity    float64  `json:"popularity,omitempty"`
+	OutOfDate     int      `json:"outOfDate,omitempty"`
+	LastModified  int      `json:"lastModified,omitempty"`
+	Size          int64    `json:"size,omitempty"`
+	InstalledSize int64    `json:"installedSize,omitempty"`
+	Path          string   `json:"path,omitempty"`
+}
+
+func aurFormatPkg(pkg *rpc.Pkg) formatPkg {
+	return formatPkg{
+		Source:       "aur",
+		Repository:   "aur",
+		Name:         pkg.Name,
+		Base:         pkg.PackageBase,
+		Version:      pkg.Version,
+		Description:  pkg.Description,
+		URL:          pkg.URL,
+		Licenses:     pkg.License,
+		Groups:       pkg.Groups,
+		Provides:     pkg.Provides,
+		Depends:      pkg.Depends,
+		MakeDepends:  pkg.MakeDepends,
+		CheckDepends: pkg.CheckDepends,
+		OptDepends:   pkg.OptDepends,
+		Conflicts:    pkg.Conflicts,
+		Replaces:     pkg.Replaces,
+		Maintainer:   pkg.Maintainer,
+		Votes:        pkg.NumVotes,
+		Popularity:   pkg.Popularity,
+		OutOfDate:    pkg.OutOfDate,
+		LastModified: pkg.LastModified,
+	}
+}
+
+func repoFormatPkg(pkg *alpm.Package) formatPkg {
+	source := "repo"
+	if pkg.DB().Name() == "local" {
+		source = "local"
+	}
+
+	return formatPkg{
+		Source:        source,
+		Repository:    pkg.DB().Name(),
+		Name:          pkg.Name(),
+		Base:          pkg.Base(),
+		Version:       pkg.Version(),
+		Description:   pkg.Description(),
+		URL:           pkg.URL(),
+		Licenses:      pkg.Licenses().Slice(),
+		Groups:        pkg.Groups().Slice(),
+		Provides:      dependStrings(pkg.Provides()),
+		Depends:       dependStrings(pkg.Depends()),
+		MakeDepends:   dependStrings(pkg.MakeDepends()),
+		CheckDepends:  dependStrings(pkg.CheckDepends()),
+		OptDepends:    optDependStrings(pkg.OptionalDepends()),
+		Conflicts:     dependStrings(pkg.Conflicts()),
+		Replaces:      dependStrings(pkg.Replaces()),
+		Size:          pkg.Size(),
+		InstalledSize: pkg.ISize(),
+	}
+}
+
+// upgradeFormatPkg describes an upgrade as the package it upgrades to, the
+// installed version being the one it upgrades from.
+func upgradeFormatPkg(u upgrade) formatPkg {
+	source := "repo"
+	if u.Repository == "aur" || u.Repository == "devel" {
+		source = "aur"
+	}
+
+	return formatPkg{
+		Source:     source,
+		Repository: u.Repository,
+		Name:       u.Name,
+		Version:    u.RemoteVersion,
+		Installed:  u.LocalVersion,
+	}
+}
+
+// setInstalled fills in the installed version of the packages.
+func setInstalled(pkgs []formatPkg) {
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return
+	}
+
+	for i := range pkgs {
+		if pkg := localDB.Pkg(pkgs[i].Name); pkg != nil {
+			pkgs[i].Installed = pkg.Version()
+		}
+	}
+}
+
+// searchRecords returns the results of a search, repo packages first.
+func searchRecords(pq repoQuery, aq aurQuery) []formatPkg {
+	records := make([]formatPkg, 0, len(pq)+len(aq))
+	for i := range pq {
+		records = append(records, repoFormatPkg(&pq[i]))
+	}
+	for i := range aq {
+		records = append(records, aurFormatPkg(&aq[i]))
+	}
+
+	setInstalled(records)
+
+	return records
+}
+
+// syncInfoFormatted is -Si with --format. Repo packages are looked up in the
+// sync databases instead of being passed to pacman.
+func syncInfoFormatted(aurS, repoS []string) error {
+	out, restore := redirectStdout()
+	defer restore()
+
+	dbList, err := alpmHandle.SyncDBs()
+	if err != nil {
+		return err
+	}
+
+	records := make([]formatPkg, 0, len(aurS)+len(repoS))
+	missing := false
+
+	for _, target := range repoS {
+		dbName, name := splitDBFromName(target)
+		var pkg *alpm.Package
+		_ = dbList.ForEach(func(db alpm.DB) error {
+			if dbName != "" && db.Name() != dbName {
+				return nil
+			}
+			if pkg = db.Pkg(name); pkg != nil {
+				return fmt.Errorf("")
+			}
+			return nil
+		})
+
+		if pkg == nil {
+			fmt.Fprintln(os.Stderr, red(bold("error:")), "package '"+target+"' was not found")
+			missing = true
+			continue
+		}
+		records = append(records, repoFormatPkg(pkg))
+	}
+
+	if len(aurS) != 0 {
+		names := make([]string, 0, len(aurS))
+		for _, target := range aurS {
+			_, name := splitDBFromName(target)
+			names = append(names, name)
+		}
+
+		info, err := aurInfoPrint(names)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if err != nil || len(info) != len(names) {
+			missing = true
+		}
+
+		for _, pkg := range info {
+			records = append(records, aurFormatPkg(pkg))
+		}
+	}
+
+	setInstalled(records)
+
+	if err = printFormatted(out, config.Format, records); err != nil {
+		return err
+	}
+
+	if missing {
+		return fmt.Errorf("")
+	}
+
+	return nil
+}
+
+// biggestFormatPkgs returns the n biggest installed packages.
+func biggestFormatPkgs(n int) []formatPkg {
+	biggest := make([]formatPkg, 0, n)
+
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return biggest
+	}
+
+	pkgS := localDB.PkgCache().SortBySize().Slice()
+	for i := 0; i < n && i < len(pkgS); i++ {
+		biggest = append(biggest, repoFormatPkg(&pkgS[i]))
+	}
+
+	return biggest
+}
+
+// updateCount is -Pn as --format prints it.
+type updateCount struct {
+	Repo  int `json:"repo"`
+	Aur   int `json:"aur"`
+	Total int `json:"total"`
+}
+
+// formatStats is -Ps as --format prints it.
+type formatStats struct {
+	Version   string          `json:"version"`
+	Installed int             `json:"installed"`
+	Foreign   int             `json:"foreign"`
+	Explicit  int             `json:"explicit"`
+	TotalSize int64           `json:"totalSize"`
+	Biggest   []formatPkg     `json:"biggest"`
+	Moved     []repoMigration `json:"moved"`
+	Warnings  *aurWarnings    `json:"warnings"`
+}
+
+// newsItem is a news item as --format prints it.
+type newsItem struct {
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	Date        time.Time `json:"date"`
+	Creator     string    `json:"creator"`
+	Description string    `json:"description"`
+}
+
+// formatNews returns the news in items, skipping the ones published before
+// buildTime unless all is set. Descriptions are left uncolored.
+func formatNews(items []item, buildTime time.Time, all bool) []newsItem {
+	uncolor := strings.NewReplacer(cyanCode, "", resetCode, "")
+	news := make([]newsItem, 0, len(items))
+	for _, item := range items {
+		date, err := time.Parse(time.RFC1123Z, item.PubDate)
+		if err == nil && !all && !buildTime.IsZero() && buildTime.After(date) {
+			continue
+		}
+
+		news = append(news, newsItem{
+			strings.TrimSpace(item.Title),
+			strings.TrimSpace(item.Link),
+			date,
+			item.Creator,
+			strings.TrimSpace(uncolor.Replace(parseNews(item.Description))),
+		})
+	}
+
+	return news
+}
+
+// redirectStdout sends stdout to stderr when --format is given so that only
+// the formatted output goes to stdout. It returns the real stdout and a
+// function restoring it.
+func redirectStdout() (*os.File, func()) {
+	if config.Format == "" {
+		return os.Stdout, func() {}
+	}
+
+	return stdoutToStderr()
+}
+
+// printFormatted writes v to out as JSON when format is json. Any other
+// format is a template executed on v, or on every element of v when it is a
+// list, each execution followed by a newline.
+func printFormatted(out io.Writer, format string, v interface{}) error {
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		return enc.Encode(v)
+	}
+
+	tmpl, err := template.New("format").Funcs(template.FuncMap{"join": strings.Join}).Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid format: %s", err)
+	}
+
+	values := []interface{}{v}
+	if list := reflect.ValueOf(v); list.Kind() == reflect.Slice {
+		values = make([]interface{}, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			values = append(values, list.Index(i).Interface())
+		}
+	}
+
+	for _, value := range values {
+		if err = tmpl.Execute(out, value); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestPrintFormatted(t *testing.T) {
+	records := []formatPkg{
+		aurFormatPkg(&rpc.Pkg{Name: "yay", PackageBase: "yay", Version: "9.2.1-1", Depends: []string{"pacman>=5.1", "sudo"}}),
+		upgradeFormatPkg(upgrade{"pacman", "core", "5.1.3-1", "5.2.0-1"}),
+	}
+
+	var buf bytes.Buffer
+	if err := printFormatted(&buf, "json", records); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 records got %d", len(decoded))
+	}
+	if decoded[0]["source"] != "aur" || decoded[0]["repository"] != "aur" || decoded[0]["base"] != "yay" {
+		t.Errorf("unexpected aur record %v", decoded[0])
+	}
+	if decoded[1]["source"] != "repo" || decoded[1]["version"] != "5.2.0-1" || decoded[1]["installed"] != "5.1.3-1" {
+		t.Errorf("unexpected upgrade record %v", decoded[1])
+	}
+	if _, ok := decoded[1]["depends"]; ok {
+		t.Errorf("expected empty fields to be left out of %v", decoded[1])
+	}
+
+	buf.Reset()
+	if err := printFormatted(&buf, `{{.Source}}/{{.Name}} {{.Version}} {{join .Depends ","}}`, records); err != nil {
+		t.Fatal(err)
+	}
+	expected := "aur/yay 9.2.1-1 pacman>=5.1,sudo\nrepo/pacman 5.2.0-1 \n"
+	if buf.String() != expected {
+		t.Errorf("expected %q got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := printFormatted(&buf, "{{.Total}}", updateCount{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "3\n" {
+		t.Errorf("expected %q got %q", "3\n", buf.String())
+	}
+
+	if err := printFormatted(&buf, "{{.Name", records); err == nil {
+		t.Errorf("expected an error for an invalid template")
+	}
+}
+
+func TestFormatNews(t *testing.T) {
+	items := []item{
+		{Title: " New ", PubDate: "Tue, 02 Jul 2019 10:00:00 +0000", Description: "<p>new</p>"},
+		{Title: "Old", PubDate: "Mon, 01 Jan 2018 10:00:00 +0000"},
+		{Title: "Undated", PubDate: "yesterday"},
+	}
+	buildTime := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	news := formatNews(items, buildTime, false)
+	if len(news) != 2 || news[0].Title != "New" || news[1].Title != "Undated" {
+		t.Errorf("expected [New Undated] got %v", news)
+	}
+	if news[0].Description != "new" {
+		t.Errorf("expected description new got %q", news[0].Description)
+	}
+
+	if news = formatNews(items, buildTime, true); len(news) != 3 {
+		t.Errorf("expected 3 items got %d", len(news))
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// graphNode is a package in the exported graph.
+type graphNode struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Source   string `json:"source"`
+	Root     bool   `json:"root"`
+	Unneeded bool   `json:"unneeded,omitempty"`
+}
+
+// graphEdge is a dependency in the exported graph. Provides is the name the
+// dependency asks for when To satisfies it through a provide.
+type graphEdge struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Type       string `json:"type"`
+	Dependency string `json:"dependency"`
+	Provides   string `json:"provides,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+type exportedGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+func makeExportedGraph(g *depGraph) *exportedGraph {
+	exported := &exportedGraph{
+		make([]graphNode, 0, len(g.nodes)),
+		make([]graphEdge, 0),
+	}
+
+	for _, name := range g.names() {
+		node := g.nodes[name]
+		exported.Nodes = append(exported.Nodes, graphNode{node.name, node.version, node.source, node.root, node.unneeded})
+
+		for _, edge := range g.edges[name] {
+			_, mod, ver := splitDep(edge.dep)
+			exported.Edges = append(exported.Edges, graphEdge{edge.from, edge.to, edge.kind, edge.dep, edge.provided(), mod + ver})
+		}
+	}
+
+	return exported
+}
+
+func (exported *exportedGraph) writeDot(out io.Writer) {
+	fmt.Fprintln(out, "digraph dependencies {")
+	fmt.Fprintln(out, "\trankdir=LR;")
+	fmt.Fprintln(out, "\tnode [shape=box];")
+
+	for _, node := range exported.Nodes {
+		label := node.Name
+		if node.Version != "" {
+			label += "\n" + node.Version
+		}
+
+		attrs := []string{"label=" + strconv.Quote(label)}
+		switch node.Source {
+		case "aur":
+			attrs = append(attrs, "color=blue")
+		case "local":
+			attrs = append(attrs, "style=dashed")
+		case "group":
+			attrs = append(attrs, "shape=folder")
+		}
+		if node.Root {
+			attrs = append(attrs, "penwidth=2")
+		}
+		if node.Unneeded {
+			attrs = append(attrs, "color=red", "fontcolor=red")
+		}
+
+		fmt.Fprintf(out, "\t%s [%s];\n", strconv.Quote(node.Name), strings.Join(attrs, ", "))
+	}
+
+	for _, edge := range exported.Edges {
+		label := make([]string, 0, 2)
+		if edge.Type != edgeDepends {
+			label = append(label, edge.Type)
+		}
+		if edge.Provides != "" || edge.Constraint != "" {
+			label = append(label, edge.Dependency)
+		}
+
+		attrs := make([]string, 0, 3)
+		if len(label) > 0 {
+			attrs = append(attrs, "label="+strconv.Quote(strings.Join(label, "\n")))
+		}
+		switch edge.Type {
+		case edgeMakeDepends:
+			attrs = append(attrs, "style=dashed")
+		case edgeCheckDepends:
+			attrs = append(attrs, "style=dotted")
+		case edgeOptDepends:
+			attrs = append(attrs, "style=dotted", "color=gray")
+		case edgeGroup:
+			attrs = append(attrs, "style=bold")
+		}
+
+		fmt.Fprintf(out, "\t%s -> %s", strconv.Quote(edge.From), strconv.Quote(edge.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(out, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(out, ";")
+	}
+
+	fmt.Fprintln(out, "}")
+}
+
+func printDepGraph(out io.Writer, format string, g *depGraph) error {
+	exported := makeExportedGraph(g)
+
+	switch format {
+	case "dot":
+		exported.writeDot(out)
+		return nil
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		return enc.Encode(exported)
+	}
+
+	return fmt.Errorf("invalid graph format '%s', expected dot or json", format)
+}
+
+// graphFormat returns the format given to --graph, dot when none is given.
+func graphFormat() string {
+	if format, _, _ := cmdArgs.getArg("graph"); format != "" {
+		return format
+	}
+
+	return "dot"
+}
+
+// printLocalGraph prints the graph of the installed packages, or of the
+// packages the targets need when given. Packages no explicitly installed
+// package needs are marked as unneeded.
+func printLocalGraph(targets []string) error {
+	g, err := localDepGraph()
+	if err != nil {
+		return err
+	}
+
+	hanging, err := hangingPackages(false)
+	if err != nil {
+		return err
+	}
+	for _, name := range hanging {
+		if node, ok := g.nodes[name]; ok {
+			node.unneeded = true
+		}
+	}
+
+	if len(targets) > 0 {
+		for _, target := range targets {
+			if _, ok := g.nodes[target]; !ok {
+				return fmt.Errorf("package '%s' was not found", target)
+			}
+		}
+		g = g.subgraph(g.reachable(targets))
+	}
+
+	return printDepGraph(os.Stdout, graphFormat(), g)
+}
+
+// printPlannedGraph resolves the targets like an install would and prints
+// the resulting graph without installing anything.
+func printPlannedGraph(targets []string) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets specified")
+	}
+
+	// Keep stdout clean for the graph
+	out, restore := stdoutToStderr()
+	defer restore()
+
+	warnings := &aurWarnings{}
+	dp, err := getDepPool(targets, warnings)
+	if err != nil {
+		return err
+	}
+
+	return printDepGraph(out, graphFormat(), dp.depGraph())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPrintDepGraph(t *testing.T) {
+	g := makeDepGraph()
+	g.addNode("app", "1.0-1", "aur", true)
+	g.addNode("jre-openjdk", "12-1", "extra", false)
+	g.addNode("glibc", "2.29-1", "local", false)
+	g.addEdge("app", "jre-openjdk", edgeMakeDepends, "java-environment>=8")
+	g.addEdge("app", "glibc", edgeDepends, "glibc")
+
+	var out bytes.Buffer
+	if err := printDepGraph(&out, "json", g); err != nil {
+		t.Fatal(err)
+	}
+
+	var exported exportedGraph
+	if err := json.Unmarshal(out.Bytes(), &exported); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported.Nodes) != 3 || exported.Nodes[0].Name != "app" || !exported.Nodes[0].Root {
+		t.Fatalf("Unexpected nodes: %+v", exported.Nodes)
+	}
+
+	expected := []graphEdge{
+		{"app", "jre-openjdk", edgeMakeDepends, "java-environment>=8", "java-environment", ">=8"},
+		{"app", "glibc", edgeDepends, "glibc", "", ""},
+	}
+	if len(exported.Edges) != len(expected) {
+		t.Fatalf("Expected %d edges got %+v", len(expected), exported.Edges)
+	}
+	for n, edge := range exported.Edges {
+		if edge != expected[n] {
+			t.Fatalf("Edge %d: Expected %+v got %+v", n+1, expected[n], edge)
+		}
+	}
+
+	out.Reset()
+	if err := printDepGraph(&out, "dot", g); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"app" -> "jre-openjdk" [label="makedepends\njava-environment>=8", style=dashed];`) {
+		t.Fatalf("Unexpected dot output:\n%s", out.String())
+	}
+
+	if err := printDepGraph(&out, "xml", g); err == nil {
+		t.Fatal("Expected an error for an unknown format")
+	}
+}
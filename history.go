@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// historyFileName holds the name of the transaction history.
+const historyFileName string = "history.json"
+
+// historyPackage is a repo package changed by a transaction. Versions are
+// empty when they are not known.
+type historyPackage struct {
+	Name       string `json:"name"`
+	OldVersion string `json:"oldversion,omitempty"`
+	NewVersion string `json:"newversion,omitempty"`
+}
+
+// historyBase is an AUR base installed by a transaction along with the
+// package files that were installed.
+type historyBase struct {
+	Pkgbase    string   `json:"pkgbase"`
+	OldVersion string   `json:"oldversion,omitempty"`
+	NewVersion string   `json:"newversion"`
+	Files      []string `json:"files"`
+}
+
+// transaction is a single run of yay that installed something.
+type transaction struct {
+	ID      int              `json:"id"`
+	Time    time.Time        `json:"time"`
+	Command []string         `json:"command"`
+	Repo    []historyPackage `json:"repo"`
+	Aur     []historyBase    `json:"aur"`
+}
+
+// activeTransaction is the transaction of the running install. It is nil
+// outside of an install and recording into it becomes a no-op.
+var activeTransaction *transaction
+
+func historyPath() string {
+	return filepath.Join(cacheHome, historyFileName)
+}
+
+// startHistory starts recording a transaction. The returned function adds the
+// transaction to the history if anything was installed.
+func startHistory() func() {
+	if activeTransaction != nil {
+		return func() {}
+	}
+
+	activeTransaction = &transaction{
+		Time:    time.Now(),
+		Command: os.Args,
+		Repo:    make([]historyPackage, 0),
+		Aur:     make([]historyBase, 0),
+	}
+
+	return func() {
+		t := activeTransaction
+		if len(t.Repo) > 0 || len(t.Aur) > 0 {
+			if err := saveTransaction(t); err != nil {
+				fmt.Fprintln(os.Stderr, "Failed to write transaction history:", err)
+			}
+		}
+		activeTransaction = nil
+	}
+}
+
+// addRepoTargets records repo packages installed by pacman on its own, their
+// versions are not known.
+func (t *transaction) addRepoTargets(targets []string) {
+	if t == nil {
+		return
+	}
+
+	for _, target := range targets {
+		t.Repo = append(t.Repo, historyPackage{Name: target})
+	}
+}
+
+// addRepoPkgs records the sync packages pkgs, dp holds the local versions from
+// before they were installed.
+func (t *transaction) addRepoPkgs(dp *depPool, pkgs []*alpm.Package) {
+	if t == nil {
+		return
+	}
+
+	for _, pkg := range pkgs {
+		hp := historyPackage{Name: pkg.Name(), NewVersion: pkg.Version()}
+		if local := dp.LocalDB.Pkg(pkg.Name()); local != nil {
+			hp.OldVersion = local.Version()
+		}
+		t.Repo = append(t.Repo, hp)
+	}
+}
+
+func (t *transaction) addAur(bases ...historyBase) {
+	if t == nil {
+		return
+	}
+
+	t.Aur = append(t.Aur, bases...)
+}
+
+// makeHistoryBase describes base as installed from pkgdests. Only the files
+// of the packages in base are installed, pkgdests also holds the other split
+// and debug packages that were built. installed holds the versions installed
+// before the transaction.
+func makeHistoryBase(base Base, version string, pkgdests map[string]string, installed map[string]string) historyBase {
+	hb := historyBase{base.Pkgbase(), "", version, make([]string, 0, len(base))}
+
+	for _, pkg := range base {
+		if old, ok := installed[pkg.Name]; ok {
+			hb.OldVersion = old
+			break
+		}
+	}
+
+	for _, pkg := range base {
+		if pkgdest, ok := pkgdests[pkg.Name]; ok {
+			hb.Files = append(hb.Files, pkgdest)
+		}
+	}
+	sort.Strings(hb.Files)
+
+	return hb
+}
+
+func loadHistory() ([]*transaction, error) {
+	history := make([]*transaction, 0)
+
+	data, err := ioutil.ReadFile(historyPath())
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("Failed to read transaction history: %s", err)
+	}
+
+	return history, nil
+}
+
+// saveTransaction gives t the next free ID and appends it to the history.
+func saveTransaction(t *transaction) error {
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	t.ID = 1
+	if len(history) > 0 {
+		t.ID = history[len(history)-1].ID + 1
+	}
+	history = append(history, t)
+
+	marshalled, err := json.MarshalIndent(history, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(cacheHome, 0755); err != nil {
+		return err
+	}
+
+	tmp := historyPath() + ".tmp"
+	if err = writeFileSync(tmp, marshalled); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, historyPath())
+}
+
+func findTransaction(history []*transaction, arg string) (*transaction, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction id: %s", arg)
+	}
+
+	for _, t := range history {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no transaction with id %d", id)
+}
+
+func formatVersionChange(oldVersion, newVersion string) string {
+	switch {
+	case oldVersion == "" && newVersion == "":
+		return ""
+	case oldVersion == "":
+		return green(newVersion)
+	case newVersion == "":
+		return red(oldVersion)
+	default:
+		return red(oldVersion) + " -> " + green(newVersion)
+	}
+}
+
+// printHistory lists every transaction, or the transactions given by id along
+// with the package files they installed.
+func printHistory(ids []string) error {
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	details := len(ids) > 0
+	if details {
+		selected := make([]*transaction, 0, len(ids))
+		for _, id := range ids {
+			t, err := findTransaction(history, id)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, t)
+		}
+		history = selected
+	}
+
+	for _, t := range history {
+		fmt.Println(bold(cyan("::")), bold(magenta(strconv.Itoa(t.ID))),
+			t.Time.Format("2006-01-02 15:04:05"), strings.Join(t.Command, " "))
+
+		for _, pkg := range t.Repo {
+			fmt.Println("   ", bold(pkg.Name), formatVersionChange(pkg.OldVersion, pkg.NewVersion))
+		}
+
+		for _, base := range t.Aur {
+			fmt.Println("   ", bold(magenta("aur/")+base.Pkgbase), formatVersionChange(base.OldVersion, base.NewVersion))
+			if details {
+				for _, file := range base.Files {
+					fmt.Println("       ", file)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func filesExist(files []string) bool {
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			return false
+		}
+	}
+
+	return len(files) > 0
+}
+
+// pkgFileName returns the name of the package in the package file path.
+// Neither the version nor the architecture contain a dash, the name is what
+// is left after the last three dashes.
+func pkgFileName(path string) string {
+	split := strings.Split(filepath.Base(path), "-")
+	if len(split) < 4 {
+		return ""
+	}
+
+	return strings.Join(split[:len(split)-3], "-")
+}
+
+// findPackageFiles looks for the package files of the packages names of
+// pkgbase at version. The files recorded by the newest transaction that
+// installed that version are preferred, otherwise the build dir is searched.
+// Other packages of the base are left out, they were never installed.
+func findPackageFiles(history []*transaction, pkgbase string, names stringSet, version string) []string {
+	filter := func(paths []string) []string {
+		files := make([]string, 0, len(paths))
+		for _, path := range paths {
+			if !strings.HasSuffix(path, ".sig") && names.get(pkgFileName(path)) {
+				files = append(files, path)
+			}
+		}
+		return files
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		for _, base := range history[i].Aur {
+			if base.Pkgbase != pkgbase || base.NewVersion != version {
+				continue
+			}
+			if files := filter(base.Files); filesExist(files) {
+				return files
+			}
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(config.BuildDir, pkgbase, "*-"+version+"-*.pkg.tar*"))
+	return filter(matches)
+}
+
+// installedPackagesOf returns the names of the installed packages of pkgbase.
+func installedPackagesOf(pkgbase string) (stringSet, error) {
+	names := make(stringSet)
+
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return names, err
+	}
+
+	_ = localDB.PkgCache().ForEach(func(pkg alpm.Package) error {
+		if pkg.Base() == pkgbase {
+			names.set(pkg.Name())
+		}
+		return nil
+	})
+
+	return names, nil
+}
+
+// rollback reinstalls the AUR packages that transaction id upgraded at the
+// versions they had before it.
+func rollback(id string) error {
+	history, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	t, err := findTransaction(history, id)
+	if err != nil {
+		return err
+	}
+
+	arguments := makeArguments()
+	arguments.op = "U"
+
+	bases := make([]historyBase, 0, len(t.Aur))
+	for _, base := range t.Aur {
+		if base.OldVersion == "" {
+			fmt.Println(bold(yellow(arrow)),
+				cyan(base.Pkgbase)+bold(" was not installed before -- skipping"))
+			continue
+		}
+
+		names, err := installedPackagesOf(base.Pkgbase)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println(bold(yellow(arrow)),
+				cyan(base.Pkgbase)+bold(" is no longer installed -- skipping"))
+			continue
+		}
+
+		files := findPackageFiles(history, base.Pkgbase, names, base.OldVersion)
+		if len(files) == 0 {
+			return fmt.Errorf("Could not find the package files of %s-%s", base.Pkgbase, base.OldVersion)
+		}
+
+		arguments.addTarget(files...)
+		bases = append(bases, historyBase{base.Pkgbase, base.NewVersion, base.OldVersion, files})
+	}
+
+	if len(bases) == 0 {
+		fmt.Println(" there is nothing to do")
+		return nil
+	}
+
+	fmt.Println(bold(cyan("::")), bold("Rolling back transaction"), bold(magenta(strconv.Itoa(t.ID)))+bold(":"))
+	for _, base := range bases {
+		fmt.Println("   ", bold(base.Pkgbase), formatVersionChange(base.OldVersion, base.NewVersion))
+	}
+
+	defer startHistory()()
+	if err = show(passToPacman(arguments)); err != nil {
+		return fmt.Errorf("Error rolling back transaction: %d", t.ID)
+	}
+	activeTransaction.addAur(bases...)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindPackageFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := config
+	config = defaultSettings()
+	config.BuildDir = dir
+	defer func() { config = old }()
+
+	if err = os.Mkdir(filepath.Join(dir, "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := func(name string) string {
+		path := filepath.Join(dir, "foo", name)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	kept := file("foo-1.0-1-x86_64.pkg.tar.xz")
+	keptDocs := file("foo-docs-1.0-1-any.pkg.tar.xz")
+	built := file("foo-0.9-1-x86_64.pkg.tar.xz")
+	file("foo-0.9-1-x86_64.pkg.tar.xz.sig")
+	file("foo-debug-0.9-1-x86_64.pkg.tar.xz")
+	file("foo-0.9-2-x86_64.pkg.tar.xz")
+	names := sliceToStringSet([]string{"foo"})
+
+	history := []*transaction{
+		// Recorded along with a split package that was not installed
+		{ID: 1, Aur: []historyBase{{Pkgbase: "foo", NewVersion: "1.0-1", Files: []string{keptDocs, kept}}}},
+		{ID: 2, Aur: []historyBase{{Pkgbase: "bar", NewVersion: "1.0-1", Files: []string{kept}}}},
+		// Reinstalled later from files that have since been cleaned up
+		{ID: 3, Aur: []historyBase{{Pkgbase: "foo", NewVersion: "1.0-1", Files: []string{filepath.Join(dir, "gone.pkg.tar.xz")}}}},
+	}
+
+	if files := findPackageFiles(history, "foo", names, "1.0-1"); len(files) != 1 || files[0] != kept {
+		t.Errorf("expected [%s] got %v", kept, files)
+	}
+
+	// Not in the history, found in the build dir without the signature and
+	// the debug package
+	if files := findPackageFiles(history, "foo", names, "0.9-1"); len(files) != 1 || files[0] != built {
+		t.Errorf("expected [%s] got %v", built, files)
+	}
+
+	if files := findPackageFiles(history, "foo", names, "0.8-1"); len(files) != 0 {
+		t.Errorf("expected no files got %s", strings.Join(files, " "))
+	}
+}
+
+func TestMakeHistoryBase(t *testing.T) {
+	base := Base{{Name: "foo", PackageBase: "foo"}}
+	pkgdests := map[string]string{
+		"foo":       "/build/foo/foo-1.0-1-x86_64.pkg.tar.xz",
+		"foo-docs":  "/build/foo/foo-docs-1.0-1-any.pkg.tar.xz",
+		"foo-debug": "/build/foo/foo-debug-1.0-1-x86_64.pkg.tar.xz",
+	}
+
+	hb := makeHistoryBase(base, "1.0-1", pkgdests, map[string]string{"foo": "0.9-1"})
+	if len(hb.Files) != 1 || hb.Files[0] != pkgdests["foo"] {
+		t.Errorf("expected only the file of foo got %v", hb.Files)
+	}
+	if hb.OldVersion != "0.9-1" || hb.NewVersion != "1.0-1" {
+		t.Errorf("expected 0.9-1 -> 1.0-1 got %s -> %s", hb.OldVersion, hb.NewVersion)
+	}
+}
+
+func TestPkgFileName(t *testing.T) {
+	for path, name := range map[string]string{
+		"/build/foo/foo-1.0-1-x86_64.pkg.tar.xz":          "foo",
+		"foo-debug-1:1.0-1-x86_64.pkg.tar.zst":            "foo-debug",
+		"/var/cache/pacman/pkg/lib32-foo-2-3-any.pkg.tar": "lib32-foo",
+		"foo.pkg.tar.xz": "",
+	} {
+		if got := pkgFileName(path); got != name {
+			t.Errorf("expected %q for %s got %q", name, path, got)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// holdSet maps held packages to the version constraint they are held to.
+// An empty constraint holds a package at its installed version.
+type holdSet map[string]string
+
+// parseHold splits a hold given as pkg[=constraint]. The constraint is a comma
+// separated list of versions each prefixed by <, <=, =, >= or >. A version
+// without an operator must match exactly. The = separating the name may be
+// left out when the constraint starts with an operator: pkg<2.0.
+func parseHold(arg string) (string, string, error) {
+	i := strings.IndexAny(arg, "<>=")
+	if i == -1 {
+		return arg, "", nil
+	}
+
+	name, constraint := arg[:i], arg[i:]
+	if strings.HasPrefix(constraint, "=") && len(constraint) > 1 {
+		constraint = constraint[1:]
+	}
+
+	if name == "" {
+		return "", "", fmt.Errorf("invalid hold: %s", arg)
+	}
+
+	for _, part := range strings.Split(constraint, ",") {
+		if _, ver := splitConstraint(part); ver == "" {
+			return "", "", fmt.Errorf("invalid version constraint: %s", constraint)
+		}
+	}
+
+	return name, constraint, nil
+}
+
+// splitConstraint splits a single version constraint into its operator and
+// version.
+func splitConstraint(constraint string) (string, string) {
+	constraint = strings.TrimSpace(constraint)
+
+	for _, mod := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(constraint, mod) {
+			return mod, strings.TrimSpace(constraint[len(mod):])
+		}
+	}
+
+	return "=", constraint
+}
+
+func constraintSatisfied(version string, constraint string) bool {
+	for _, part := range strings.Split(constraint, ",") {
+		mod, ver := splitConstraint(part)
+		if !verSatisfies(version, mod, ver) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (holds holdSet) held(name string) bool {
+	_, ok := holds[name]
+	return ok
+}
+
+// allows reports whether name may be installed at version. installed is the
+// currently installed version of name, if any.
+func (holds holdSet) allows(name string, version string, installed string) bool {
+	constraint, ok := holds[name]
+	if !ok {
+		return true
+	}
+
+	if constraint == "" {
+		return installed == "" || alpm.VerCmp(version, installed) == 0
+	}
+
+	return constraintSatisfied(version, constraint)
+}
+
+// describe returns what name is held to for printing.
+func (holds holdSet) describe(name string, installed string) string {
+	if constraint := holds[name]; constraint != "" {
+		return constraint
+	}
+	if installed != "" {
+		return "=" + installed
+	}
+
+	return "installed version"
+}
+
+func initHolds() error {
+	data, err := ioutil.ReadFile(holdsFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to open holds file '%s': %s", holdsFile, err)
+	}
+
+	if err = json.Unmarshal(data, &holds); err != nil {
+		return fmt.Errorf("Failed to read holds '%s': %s", holdsFile, err)
+	}
+
+	return nil
+}
+
+func saveHolds() error {
+	marshalled, err := json.MarshalIndent(holds, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileSync(holdsFile, marshalled)
+}
+
+// holdPackages adds or replaces the holds given as pkg[=constraint].
+func holdPackages(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no packages to hold")
+	}
+
+	if holds == nil {
+		holds = make(holdSet)
+	}
+
+	for _, arg := range args {
+		name, constraint, err := parseHold(arg)
+		if err != nil {
+			return err
+		}
+
+		holds[name] = constraint
+		if constraint == "" {
+			fmt.Println(bold(cyan("::")), bold("Holding"), cyan(name))
+		} else {
+			fmt.Println(bold(cyan("::")), bold("Holding"), cyan(name), bold("to"), constraint)
+		}
+	}
+
+	return saveHolds()
+}
+
+func unholdPackages(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no packages to unhold")
+	}
+
+	for _, name := range names {
+		if !holds.held(name) {
+			fmt.Println(bold(yellow(arrow)), cyan(name)+bold(" is not held -- skipping"))
+			continue
+		}
+
+		delete(holds, name)
+		fmt.Println(bold(cyan("::")), bold("Unholding"), cyan(name))
+	}
+
+	return saveHolds()
+}
+
+// printHolds lists the held packages along with their installed versions.
+func printHolds() error {
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(holds))
+	for name := range holds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		installed := ""
+		status := yellow("not installed")
+		if pkg := localDB.Pkg(name); pkg != nil {
+			installed = pkg.Version()
+			status = green(installed)
+		}
+
+		fmt.Println(bold(name), holds.describe(name, installed), status)
+	}
+
+	return nil
+}
+
+func printHoldingPackage(pkg alpm.Package, newPkgVersion string) {
+	left, right := getVersionDiff(pkg.Version(), newPkgVersion)
+
+	fmt.Printf("%s %s: holding package upgrade (%s => %s, held at %s)\n",
+		yellow(bold(smallArrow)),
+		cyan(pkg.Name()),
+		left, right,
+		holds.describe(pkg.Name(), pkg.Version()),
+	)
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestParseHold(t *testing.T) {
+	type result struct {
+		Name       string
+		Constraint string
+		Err        bool
+	}
+
+	inputs := []string{
+		"foo",
+		"foo=<2.0",
+		"foo<2.0",
+		"foo=2.0-1",
+		"foo>=1.4,<2.0",
+		"foo=>=1:1.4",
+		"foo=",
+		"=1.0",
+		"foo<2.0,",
+	}
+
+	expected := []result{
+		{"foo", "", false},
+		{"foo", "<2.0", false},
+		{"foo", "<2.0", false},
+		{"foo", "2.0-1", false},
+		{"foo", ">=1.4,<2.0", false},
+		{"foo", ">=1:1.4", false},
+		{"", "", true},
+		{"", "", true},
+		{"", "", true},
+	}
+
+	for n, in := range inputs {
+		res := expected[n]
+		name, constraint, err := parseHold(in)
+
+		if name != res.Name || constraint != res.Constraint || (err != nil) != res.Err {
+			t.Fatalf("Test %d Failed: Expected: name=%q constraint=%q err=%t got name=%q constraint=%q err=%v",
+				n+1, res.Name, res.Constraint, res.Err, name, constraint, err)
+		}
+	}
+}
+
+func TestSplitConstraint(t *testing.T) {
+	inputs := []string{"<2.0", "<=2.0", "=2.0", ">= 2.0", ">2.0", "2.0", " <2.0 "}
+	expected := [][2]string{
+		{"<", "2.0"},
+		{"<=", "2.0"},
+		{"=", "2.0"},
+		{">=", "2.0"},
+		{">", "2.0"},
+		{"=", "2.0"},
+		{"<", "2.0"},
+	}
+
+	for n, in := range inputs {
+		mod, ver := splitConstraint(in)
+		if mod != expected[n][0] || ver != expected[n][1] {
+			t.Fatalf("Test %d Failed: Expected: %q %q got %q %q", n+1, expected[n][0], expected[n][1], mod, ver)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maxRetryDelay caps the wait between two attempts, including the one the
+// server asks for.
+const maxRetryDelay = 30 * time.Second
+
+// retryTransport retries requests that failed to connect, were reset or got
+// a 5xx or 429 response, waiting exponentially longer between attempts. It
+// sets the User-Agent of every request and fails reading a response body that
+// receives no data for idle.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	delay   time.Duration
+	agent   string
+	idle    time.Duration
+}
+
+// idleTimeoutBody closes a response body that receives no data for timeout,
+// failing the pending read instead of leaving it hanging.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func makeIdleTimeoutBody(body io.ReadCloser, timeout time.Duration) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.expired, 1)
+		body.Close()
+	})
+
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if atomic.LoadInt32(&b.expired) == 1 {
+		return n, fmt.Errorf("no data received for %s", b.timeout)
+	}
+	b.timer.Reset(b.timeout)
+
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
+
+// retryable reports whether a request that failed with err is worth another
+// attempt.
+func retryable(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "dial" {
+		return true
+	}
+
+	return strings.Contains(err.Error(), syscall.ECONNRESET.Error())
+}
+
+// retryAfter returns the delay a 429 or 503 response asks for in its
+// Retry-After header, given in seconds or as a date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if date.Before(now) {
+			return 0, true
+		}
+		return date.Sub(now), true
+	}
+
+	return 0, false
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		// RoundTrip must not modify the request
+		clone := *req
+		clone.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			clone.Header[k] = v
+		}
+		clone.Header.Set("User-Agent", t.agent)
+		req = &clone
+	}
+
+	// Only requests that can be sent again are retried
+	retries := t.retries
+	if req.Body != nil && req.GetBody == nil {
+		retries = 0
+	}
+
+	delay := t.delay
+	for attempt := 0; ; attempt++ {
+		// Every attempt gets its own copy with a fresh body
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			clone := *req
+			clone.Body = body
+			attemptReq = &clone
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+
+		wait := delay
+		switch {
+		case err != nil:
+			if attempt >= retries || !retryable(err) {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			if attempt >= retries {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusTooManyRequests {
+					return nil, fmt.Errorf("rate limited (%s), try again later", resp.Status)
+				}
+				return nil, fmt.Errorf("%s after %d attempts", resp.Status, attempt+1)
+			}
+			if after, ok := retryAfter(resp, time.Now()); ok {
+				wait = after
+			}
+			resp.Body.Close()
+		default:
+			if t.idle > 0 {
+				resp.Body = makeIdleTimeoutBody(resp.Body, t.idle)
+			}
+			return resp, nil
+		}
+
+		if wait > maxRetryDelay {
+			wait = maxRetryDelay
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		delay *= 2
+	}
+}
+
+// makeHTTPClient returns the client used for every request yay makes. Proxies
+// are taken from the environment. Reading a response fails once no data
+// arrived for timeout.
+func makeHTTPClient(timeout time.Duration, retries int) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			&http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   timeout,
+				ResponseHeaderTimeout: timeout,
+				IdleConnTimeout:       90 * time.Second,
+				MaxIdleConns:          100,
+				ExpectContinueTimeout: time.Second,
+			},
+			retries,
+			500 * time.Millisecond,
+			"yay/" + version,
+			timeout,
+		},
+	}
+}
+
+// initHTTP replaces the default client, which the rpc client uses as well.
+func initHTTP() {
+	http.DefaultClient = makeHTTPClient(time.Duration(config.RequestTimeout)*time.Second, config.RequestRetries)
+}
+
+// httpGet gets url, failing with an error naming the url when the response
+// is not a success.
+func httpGet(url string) (*http.Response, error) {
+	resp, err := http.DefaultClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testHTTPClient(retries int) *http.Client {
+	client := makeHTTPClient(time.Second, retries)
+	client.Transport.(*retryTransport).delay = time.Millisecond
+	return client
+}
+
+func TestRetryTransport(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if agent := r.Header.Get("User-Agent"); agent != "yay/"+version {
+			t.Errorf("expected User-Agent yay/%s got %s", version, agent)
+		}
+
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	resp, err := testHTTPClient(3).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "ok" || requests != 3 {
+		t.Errorf("expected ok after 3 requests got %q after %d", body, requests)
+	}
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := testHTTPClient(2).Get(server.URL + "/rpc.php")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), server.URL+"/rpc.php") || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("expected a rate limit error naming the url got %s", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests got %d", requests)
+	}
+
+	requests = 0
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	})
+
+	resp, err := testHTTPClient(2).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || requests != 1 {
+		t.Errorf("expected a single 404 got %s after %d requests", resp.Status, requests)
+	}
+}
+
+func TestRetryTransportBody(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "data" {
+			t.Errorf("expected the body data got %q", body)
+		}
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest("POST", server.URL, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+
+	resp, err := testHTTPClient(1).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if requests != 2 {
+		t.Errorf("expected 2 requests got %d", requests)
+	}
+	if req.Body != body {
+		t.Errorf("expected the body of the request to be left alone")
+	}
+}
+
+func TestIdleTimeoutBody(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := makeHTTPClient(100*time.Millisecond, 0)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		t.Errorf("expected the read to time out got %q", body)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}
+
+	testCases := []testCase{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Wed, 01 May 2019 12:00:10 GMT", 10 * time.Second, true},
+		{"Wed, 01 May 2019 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tc := range testCases {
+		resp := &http.Response{Header: make(http.Header)}
+		if tc.value != "" {
+			resp.Header.Set("Retry-After", tc.value)
+		}
+
+		delay, ok := retryAfter(resp, now)
+		if delay != tc.delay || ok != tc.ok {
+			t.Errorf("%q: expected %s, %t got %s, %t", tc.value, tc.delay, tc.ok, delay, ok)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -21,7 +22,7 @@ func asdeps(parser *arguments, pkgs []string) error {
 	parser = parser.copyGlobal()
 	parser.addArg("D", "asdeps")
 	parser.addTarget(pkgs...)
-	_, stderr, err := capture(passToPacman(parser))
+	_, stderr, err := captureLog(passToPacman(parser))
 	if err != nil {
 		return fmt.Errorf("%s%s", stderr, err)
 	}
@@ -37,7 +38,7 @@ func asexp(parser *arguments, pkgs []string) error {
 	parser = parser.copyGlobal()
 	parser.addArg("D", "asexplicit")
 	parser.addTarget(pkgs...)
-	_, stderr, err := capture(passToPacman(parser))
+	_, stderr, err := captureLog(passToPacman(parser))
 	if err != nil {
 		return fmt.Errorf("%s%s", stderr, err)
 	}
@@ -57,7 +58,30 @@ func install(parser *arguments) (err error) {
 
 	warnings := &aurWarnings{}
 
-	if mode == modeAny || mode == modeRepo {
+	// Keep stdout clean for the plan, everything else goes to stderr
+	var planOut io.Writer = os.Stdout
+	if config.PrintPlan == "json" {
+		out, restore := stdoutToStderr()
+		defer restore()
+		planOut = out
+	}
+
+	// Runs once the logs and history of this install are closed, the
+	// rebuild is an install of its own
+	if config.BrokenCheck && parser.existsArg("u", "sysupgrade") && config.PrintPlan == "" {
+		defer func() {
+			if err == nil {
+				err = checkBrokenPackages()
+			}
+		}()
+	}
+
+	if config.PrintPlan == "" {
+		defer startBuildLogs()()
+		defer startHistory()()
+	}
+
+	if (mode == modeAny || mode == modeRepo) && config.PrintPlan == "" {
 		if config.CombinedUpgrade {
 			if parser.existsArg("y", "refresh") {
 				err = earlyRefresh(parser)
@@ -108,7 +132,14 @@ func install(parser *arguments) (err error) {
 			return err
 		}
 
-		warnings.print()
+		if config.PrintPlan == "" {
+			warnings.Moved = chooseRepoMigrations(warnings.Moved)
+		}
+		warnings.Missing = removeMigrated(warnings.Missing, warnings.Moved)
+
+		if config.PrintPlan == "" {
+			warnings.print()
+		}
 
 		ignore, aurUp, err := upgradePkgs(aurUp, repoUp)
 		if err != nil {
@@ -122,6 +153,14 @@ func install(parser *arguments) (err error) {
 			}
 		}
 
+		// The repo package conflicts with the foreign one, pacman
+		// removes it in the same transaction
+		for _, m := range warnings.Moved {
+			delete(aurUp, m.Name)
+			requestTargets = append(requestTargets, m.Repository+"/"+m.RepoName)
+			parser.addTarget(m.Repository + "/" + m.RepoName)
+		}
+
 		for up := range aurUp {
 			requestTargets = append(requestTargets, "aur/"+up)
 			parser.addTarget("aur/" + up)
@@ -150,7 +189,7 @@ func install(parser *arguments) (err error) {
 		return err
 	}
 
-	if len(dp.Aur) == 0 {
+	if len(dp.Aur) == 0 && config.PrintPlan == "" {
 		if !config.CombinedUpgrade {
 			if parser.existsArg("u", "sysupgrade") {
 				fmt.Println(" there is nothing to do")
@@ -161,10 +200,14 @@ func install(parser *arguments) (err error) {
 		parser.op = "S"
 		parser.delArg("y", "refresh")
 		parser.options["ignore"] = arguments.options["ignore"]
-		return show(passToPacman(parser))
+		err = showLog(passToPacman(parser))
+		if err == nil {
+			activeTransaction.addRepoTargets(parser.targets)
+		}
+		return err
 	}
 
-	if len(dp.Aur) > 0 && os.Geteuid() == 0 {
+	if len(dp.Aur) > 0 && os.Geteuid() == 0 && config.PrintPlan == "" {
 		return fmt.Errorf(bold(red(arrow)) + " Refusing to install AUR Packages as root, Aborting.")
 	}
 
@@ -173,11 +216,15 @@ func install(parser *arguments) (err error) {
 		return err
 	}
 
-	do = getDepOrder(dp)
+	do, err = getDepOrder(dp)
 	if err != nil {
 		return err
 	}
 
+	if config.PrintPlan != "" {
+		return printTransactionPlan(planOut, config.PrintPlan, dp, do, conflicts, warnings)
+	}
+
 	for _, pkg := range do.Repo {
 		arguments.addTarget(pkg.DB().Name() + "/" + pkg.Name())
 	}
@@ -194,26 +241,39 @@ func install(parser *arguments) (err error) {
 	do.Print()
 	fmt.Println()
 
+	// Cleaning up is left to --resume when the build can be resumed
+	jr := makeInstallJournal(dp, do, parser, conflicts)
+
 	if config.CleanAfter {
-		defer cleanAfter(do.Aur)
+		defer func() {
+			if !jr.pending() {
+				cleanAfter(do.Aur)
+			}
+		}()
 	}
 
 	if do.HasMake() {
 		switch config.RemoveMake {
 		case "yes":
-			defer removeMake(do, &err)
+			jr.RemoveMake = true
 		case "no":
 			break
 		default:
-			if continueTask("Remove make dependencies after install?", false) {
-				defer removeMake(do, &err)
-			}
+			jr.RemoveMake = continueTask("Remove make dependencies after install?", false)
+		}
+
+		if jr.RemoveMake {
+			defer func() {
+				if !jr.pending() {
+					removeMake(do, &err)
+				}
+			}()
 		}
 	}
 
 	if config.CleanMenu {
 		if anyExistInCache(do.Aur) {
-			askClean := pkgbuildNumberMenu(do.Aur, remoteNamesCache)
+			askClean := pkgbuildNumberMenu(config.BuildDir, do.Aur, remoteNamesCache)
 			toClean, err := cleanNumberMenu(do.Aur, remoteNamesCache, askClean)
 			if err != nil {
 				return err
@@ -223,73 +283,65 @@ func install(parser *arguments) (err error) {
 		}
 	}
 
-	toSkip := pkgbuildsToSkip(do.Aur, targets)
-	cloned, err := downloadPkgbuilds(do.Aur, toSkip, config.BuildDir)
+	srcinfos, toEdit, err := reviewPkgbuilds(do.Aur, pkgbuildsToSkip(do.Aur, targets), remoteNamesCache)
 	if err != nil {
 		return err
 	}
 
-	var toDiff []Base
-	var toEdit []Base
+	// The .SRCINFO files can change what has to be installed, bases this
+	// adds go through the menus and can in turn add more
+	reresolved := false
+	checksChanged := false
+	for {
+		if config.SrcinfoDeps {
+			do, err = resolveFromSrcinfo(dp, do, srcinfos, toEdit, remoteNamesCache)
+			if err != nil {
+				return err
+			}
+			toEdit = nil
+			reresolved = true
+		}
 
-	if config.DiffMenu {
-		pkgbuildNumberMenu(do.Aur, remoteNamesCache)
-		toDiff, err = diffNumberMenu(do.Aur, remoteNamesCache)
+		var changed bool
+		do, changed, err = dp.resolveCheckDeps(do)
 		if err != nil {
 			return err
 		}
+		checksChanged = checksChanged || changed
 
-		if len(toDiff) > 0 {
-			err = showPkgbuildDiffs(toDiff, cloned)
-			if err != nil {
-				return err
-			}
+		var newBases []Base
+		newBases, err = reviewNewBases(do, srcinfos, remoteNamesCache)
+		if err != nil {
+			return err
 		}
-	}
-
-	if len(toDiff) > 0 {
-		oldValue := config.NoConfirm
-		config.NoConfirm = false
-		fmt.Println()
-		if !continueTask(bold(green("Proceed with install?")), true) {
-			return fmt.Errorf("Aborting due to user")
+		if len(newBases) == 0 {
+			break
 		}
-		config.NoConfirm = oldValue
 	}
 
-	err = mergePkgbuilds(do.Aur)
-	if err != nil {
-		return err
+	if checksChanged {
+		fmt.Println(bold(cyan("::")), bold("The check options of the PKGBUILDs changed what will be installed:"))
+		do.Print()
+		fmt.Println()
 	}
 
-	srcinfos, err = parseSrcinfoFiles(do.Aur, true)
-	if err != nil {
-		return err
-	}
+	if reresolved || checksChanged {
+		arguments.clearTargets()
+		for _, pkg := range do.Repo {
+			arguments.addTarget(pkg.DB().Name() + "/" + pkg.Name())
+		}
+		for _, pkg := range dp.Groups {
+			arguments.addTarget(pkg)
+		}
 
-	if config.EditMenu {
-		pkgbuildNumberMenu(do.Aur, remoteNamesCache)
-		toEdit, err = editNumberMenu(do.Aur, remoteNamesCache)
+		conflicts, err = dp.CheckConflicts()
 		if err != nil {
 			return err
 		}
 
-		if len(toEdit) > 0 {
-			err = editPkgbuilds(toEdit, srcinfos)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	if len(toEdit) > 0 {
-		oldValue := config.NoConfirm
-		config.NoConfirm = false
-		fmt.Println()
-		if !continueTask(bold(green("Proceed with install?")), true) {
-			return fmt.Errorf("Aborting due to user")
-		}
-		config.NoConfirm = oldValue
+		removeMake := jr.RemoveMake
+		jr = makeInstallJournal(dp, do, parser, conflicts)
+		jr.RemoveMake = removeMake
 	}
 
 	incompatible, err = getIncompatible(do.Aur, srcinfos)
@@ -309,10 +361,11 @@ func install(parser *arguments) (err error) {
 	}
 
 	if len(arguments.targets) > 0 || arguments.existsArg("u") {
-		err := show(passToPacman(arguments))
+		err := showLog(passToPacman(arguments))
 		if err != nil {
 			return fmt.Errorf("Error installing repo packages")
 		}
+		activeTransaction.addRepoPkgs(dp, do.Repo)
 
 		deps := make([]string, 0)
 		exp := make([]string, 0)
@@ -340,31 +393,45 @@ func install(parser *arguments) (err error) {
 
 	go updateCompletion(false)
 
-	err = downloadPkgbuildsSources(do.Aur, incompatible)
+	err = downloadPkgbuildsSources(config.BuildDir, do.Aur, incompatible)
 	if err != nil {
 		return err
 	}
 
-	err = buildInstallPkgbuilds(dp, do, srcinfos, parser, incompatible, conflicts)
+	jr.Incompatible = incompatible.toSlice()
+	if err = jr.save(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write transaction journal:", err)
+	}
+
+	err = buildInstallPkgbuilds(config.BuildDir, dp, do, srcinfos, parser, incompatible, conflicts, jr)
 	if err != nil {
+		if jr.pending() {
+			printResumeHint()
+		}
 		return err
 	}
 
+	jr.remove()
 	return nil
 }
 
 func removeMake(do *depOrder, err *error) {
+	*err = removeMakeDeps(do.getMake())
+}
+
+func removeMakeDeps(pkgs []string) error {
 	removeArguments := makeArguments()
 	removeArguments.addArg("R", "u")
 
-	for _, pkg := range do.getMake() {
+	for _, pkg := range pkgs {
 		removeArguments.addTarget(pkg)
 	}
 
 	oldValue := config.NoConfirm
 	config.NoConfirm = true
-	*err = show(passToPacman(removeArguments))
+	err := showLog(passToPacman(removeArguments))
 	config.NoConfirm = oldValue
+	return err
 }
 
 func inRepos(syncDB alpm.DBList, pkg string) bool {
@@ -413,10 +480,11 @@ func earlyPacmanCall(parser *arguments) error {
 	}
 
 	if parser.existsArg("y", "refresh") || parser.existsArg("u", "sysupgrade") || len(arguments.targets) > 0 {
-		err = show(passToPacman(arguments))
+		err = showLog(passToPacman(arguments))
 		if err != nil {
 			return fmt.Errorf("Error installing repo packages")
 		}
+		activeTransaction.addRepoTargets(arguments.targets)
 	}
 
 	return nil
@@ -430,7 +498,7 @@ func earlyRefresh(parser *arguments) error {
 	arguments.delArg("i", "info")
 	arguments.delArg("l", "list")
 	arguments.clearTargets()
-	return show(passToPacman(arguments))
+	return showLog(passToPacman(arguments))
 }
 
 func getIncompatible(bases []Base, srcinfos map[string]*gosrc.Srcinfo) (stringSet, error) {
@@ -517,13 +585,13 @@ func anyExistInCache(bases []Base) bool {
 	return false
 }
 
-func pkgbuildNumberMenu(bases []Base, installed stringSet) bool {
+func pkgbuildNumberMenu(buildDir string, bases []Base, installed stringSet) bool {
 	toPrint := ""
 	askClean := false
 
 	for n, base := range bases {
 		pkg := base.Pkgbase()
-		dir := filepath.Join(config.BuildDir, pkg)
+		dir := filepath.Join(buildDir, pkg)
 
 		toPrint += fmt.Sprintf(magenta("%3d")+" %-40s", len(bases)-n,
 			bold(base.String()))
@@ -697,6 +765,81 @@ func editDiffNumberMenu(bases []Base, installed stringSet, diff bool) ([]Base, e
 	return toEdit, nil
 }
 
+// reviewPkgbuilds downloads the PKGBUILDs of bases, skipping the ones in
+// toSkip, and runs them through the diff and edit menus. It returns the
+// parsed .SRCINFO files and the bases that were edited.
+func reviewPkgbuilds(bases []Base, toSkip stringSet, remoteNamesCache stringSet) (map[string]*gosrc.Srcinfo, []Base, error) {
+	var toDiff []Base
+	var toEdit []Base
+
+	cloned, err := downloadPkgbuilds(bases, toSkip, config.BuildDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if config.DiffMenu {
+		pkgbuildNumberMenu(config.BuildDir, bases, remoteNamesCache)
+		toDiff, err = diffNumberMenu(bases, remoteNamesCache)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(toDiff) > 0 {
+			err = showPkgbuildDiffs(toDiff, cloned)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(toDiff) > 0 {
+		oldValue := config.NoConfirm
+		config.NoConfirm = false
+		fmt.Println()
+		if !continueTask(bold(green("Proceed with install?")), true) {
+			return nil, nil, fmt.Errorf("Aborting due to user")
+		}
+		config.NoConfirm = oldValue
+	}
+
+	err = mergePkgbuilds(bases)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srcinfos, err := parseSrcinfoFiles(bases, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if config.EditMenu {
+		pkgbuildNumberMenu(config.BuildDir, bases, remoteNamesCache)
+		toEdit, err = editNumberMenu(bases, remoteNamesCache)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(toEdit) > 0 {
+			err = editPkgbuilds(config.BuildDir, toEdit, srcinfos)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(toEdit) > 0 {
+		oldValue := config.NoConfirm
+		config.NoConfirm = false
+		fmt.Println()
+		if !continueTask(bold(green("Proceed with install?")), true) {
+			return nil, nil, fmt.Errorf("Aborting due to user")
+		}
+		config.NoConfirm = oldValue
+	}
+
+	return srcinfos, toEdit, nil
+}
+
 func showPkgbuildDiffs(bases []Base, cloned stringSet) error {
 	for _, base := range bases {
 		pkg := base.Pkgbase()
@@ -744,11 +887,11 @@ func showPkgbuildDiffs(bases []Base, cloned stringSet) error {
 	return nil
 }
 
-func editPkgbuilds(bases []Base, srcinfos map[string]*gosrc.Srcinfo) error {
+func editPkgbuilds(buildDir string, bases []Base, srcinfos map[string]*gosrc.Srcinfo) error {
 	pkgbuilds := make([]string, 0, len(bases))
 	for _, base := range bases {
 		pkg := base.Pkgbase()
-		dir := filepath.Join(config.BuildDir, pkg)
+		dir := filepath.Join(buildDir, pkg)
 		pkgbuilds = append(pkgbuilds, filepath.Join(dir, "PKGBUILD"))
 
 		for _, splitPkg := range srcinfos[pkg].SplitPackages() {
@@ -790,6 +933,7 @@ func parseSrcinfoFiles(bases []Base, errIsFatal bool) (map[string]*gosrc.Srcinfo
 			return nil, fmt.Errorf("failed to parse %s: %s", base.String(), err)
 		}
 
+		setBaseChecks(pkg, pkgbuild)
 		srcinfos[pkg] = pkgbuild
 	}
 
@@ -896,17 +1040,17 @@ func downloadPkgbuilds(bases []Base, toSkip stringSet, buildDir string) (stringS
 	return cloned, errs.Return()
 }
 
-func downloadPkgbuildsSources(bases []Base, incompatible stringSet) (err error) {
+func downloadPkgbuildsSources(buildDir string, bases []Base, incompatible stringSet) (err error) {
 	for _, base := range bases {
 		pkg := base.Pkgbase()
-		dir := filepath.Join(config.BuildDir, pkg)
+		dir := filepath.Join(buildDir, pkg)
 		args := []string{"--verifysource", "-Ccf"}
 
 		if incompatible.get(pkg) {
 			args = append(args, "--ignorearch")
 		}
 
-		err = show(passToMakepkg(dir, args...))
+		err = showLog(passToMakepkg(dir, args...), pkg)
 		if err != nil {
 			return fmt.Errorf("Error downloading sources: %s", cyan(base.String()))
 		}
@@ -915,7 +1059,7 @@ func downloadPkgbuildsSources(bases []Base, incompatible stringSet) (err error)
 	return
 }
 
-func buildInstallPkgbuilds(dp *depPool, do *depOrder, srcinfos map[string]*gosrc.Srcinfo, parser *arguments, incompatible stringSet, conflicts mapStringSet) error {
+func buildInstallPkgbuilds(buildDir string, dp *depPool, do *depOrder, srcinfos map[string]*gosrc.Srcinfo, parser *arguments, incompatible stringSet, conflicts mapStringSet, jr *installJournal) error {
 	arguments := parser.copy()
 	arguments.clearTargets()
 	arguments.op = "U"
@@ -928,141 +1072,98 @@ func buildInstallPkgbuilds(dp *depPool, do *depOrder, srcinfos map[string]*gosrc
 	arguments.delArg("u", "sysupgrade")
 	arguments.delArg("w", "downloadonly")
 
+	repo, err := getLocalRepo()
+	if err != nil {
+		return err
+	}
+
 	deps := make([]string, 0)
 	exp := make([]string, 0)
+	names := make([]string, 0)
+	queued := make([]string, 0)
+	queuedBases := make([]Base, 0)
+	queuedHistory := make([]historyBase, 0)
 	oldConfirm := config.NoConfirm
+	installed := installedVersions(dp, do)
 	config.NoConfirm = true
 
-	doInstall := func() error {
-		if len(arguments.targets) == 0 {
-			return nil
-		}
-
-		err := show(passToPacman(arguments))
-		if err != nil {
-			return err
-		}
-
-		err = saveVCSInfo()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-		}
-
-		if err = asdeps(parser, deps); err != nil {
-			return err
-		}
-		if err = asexp(parser, exp); err != nil {
-			return err
-		}
-
-		config.NoConfirm = oldConfirm
+	summary := &buildSummary{}
+	if config.KeepGoing {
+		defer summary.print()
+	}
 
+	clearQueue := func() {
 		arguments.clearTargets()
 		deps = make([]string, 0)
 		exp = make([]string, 0)
+		names = make([]string, 0)
+		queued = make([]string, 0)
+		queuedBases = make([]Base, 0)
+		queuedHistory = make([]historyBase, 0)
 		config.NoConfirm = true
-		return nil
 	}
 
-	for _, base := range do.Aur {
-		var err error
-		pkg := base.Pkgbase()
-		dir := filepath.Join(config.BuildDir, pkg)
-		built := true
-
-		satisfied := true
-	all:
-		for _, pkg := range base {
-			for _, deps := range [3][]string{pkg.Depends, pkg.MakeDepends, pkg.CheckDepends} {
-				for _, dep := range deps {
-					if _, err := dp.LocalDB.PkgCache().FindSatisfier(dep); err != nil {
-						satisfied = false
-						fmt.Printf("%s not satisfied, flushing install queue", dep)
-						break all
-					}
-				}
-			}
+	doInstall := func() error {
+		if len(arguments.targets) == 0 {
+			return nil
 		}
 
-		if !satisfied || !config.BatchInstall {
-			err = doInstall()
-			if err != nil {
+		var err error
+		if repo != nil {
+			err = repo.install(arguments, names, queued)
+		} else {
+			err = showLog(passToPacman(arguments), queued...)
+		}
+		if err != nil {
+			for _, base := range queuedBases {
+				summary.installFailed(base, err)
+			}
+			if !config.KeepGoing {
 				return err
 			}
-		}
 
-		srcinfo := srcinfos[pkg]
-
-		args := []string{"--nobuild", "-fC"}
+			// The failed bases block their dependents like a failed build
+			fmt.Fprintln(os.Stderr, bold(red(arrow)), bold("Error installing: "+strings.Join(queued, " ")+" -- continuing"))
+			config.NoConfirm = oldConfirm
+			clearQueue()
+			return nil
+		}
 
-		if incompatible.get(pkg) {
-			args = append(args, "--ignorearch")
+		for _, base := range queuedBases {
+			summary.succeeded(base)
 		}
 
-		//pkgver bump
-		err = show(passToMakepkg(dir, args...))
+		err = saveVCSInfo()
 		if err != nil {
-			return fmt.Errorf("Error making: %s", base.String())
+			fmt.Fprintln(os.Stderr, err)
 		}
 
-		pkgdests, version, err := parsePackageList(dir)
-		if err != nil {
+		if err = asdeps(parser, deps); err != nil {
 			return err
 		}
-
-		isExplicit := false
-		for _, b := range base {
-			isExplicit = isExplicit || dp.Explicit.get(b.Name)
+		if err = asexp(parser, exp); err != nil {
+			return err
 		}
-		if config.ReBuild == "no" || (config.ReBuild == "yes" && !isExplicit) {
-			for _, split := range base {
-				pkgdest, ok := pkgdests[split.Name]
-				if !ok {
-					return fmt.Errorf("Could not find PKGDEST for: %s", split.Name)
-				}
 
-				_, err := os.Stat(pkgdest)
-				if os.IsNotExist(err) {
-					built = false
-				} else if err != nil {
-					return err
-				}
-			}
-		} else {
-			built = false
+		for _, pkgbase := range queued {
+			jr.setInstalled(pkgbase)
 		}
+		activeTransaction.addAur(queuedHistory...)
 
-		if cmdArgs.existsArg("needed") {
-			installed := true
-			for _, split := range base {
-				if alpmpkg := dp.LocalDB.Pkg(split.Name); alpmpkg == nil || alpmpkg.Version() != version {
-					installed = false
-				}
-			}
+		config.NoConfirm = oldConfirm
+		clearQueue()
+		return nil
+	}
 
-			if installed {
-				show(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"))
-				fmt.Println(cyan(pkg+"-"+version) + bold(" is up to date -- skipping"))
-				continue
-			}
+	b := newBuilder()
+	if len(do.Aur) > 0 {
+		if err := b.init(dp, do); err != nil {
+			return err
 		}
+	}
 
-		if built {
-			show(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"))
-			fmt.Println(bold(yellow(arrow)),
-				cyan(pkg+"-"+version)+bold(" already made -- skipping build"))
-		} else {
-			args := []string{"-cf", "--noconfirm", "--noextract", "--noprepare", "--holdver"}
-
-			if incompatible.get(pkg) {
-				args = append(args, "--ignorearch")
-			}
-
-			err := show(passToMakepkg(dir, args...))
-			if err != nil {
-				return fmt.Errorf("Error making: %s", base.String())
-			}
-		}
+	queueInstall := func(base Base, version string, pkgdests map[string]string) error {
+		pkg := base.Pkgbase()
 
 		//conflicts have been checked so answer y for them
 		if config.UseAsk {
@@ -1096,6 +1197,7 @@ func buildInstallPkgbuilds(dp *depPool, do *depOrder, srcinfos map[string]*gosrc
 			}
 
 			arguments.addTarget(pkgdest)
+			names = append(names, split.Name)
 			if parser.existsArg("asdeps", "asdep") {
 				deps = append(deps, split.Name)
 			} else if parser.existsArg("asexplicit", "asexp") {
@@ -1104,18 +1206,212 @@ func buildInstallPkgbuilds(dp *depPool, do *depOrder, srcinfos map[string]*gosrc
 				deps = append(deps, split.Name)
 			}
 		}
+		queued = append(queued, pkg)
+		queuedBases = append(queuedBases, base)
+		queuedHistory = append(queuedHistory, makeHistoryBase(base, version, pkgdests, installed))
 
 		var mux sync.Mutex
 		var wg sync.WaitGroup
-		for _, pkg := range base {
+		for _, split := range base {
 			wg.Add(1)
-			go updateVCSData(pkg.Name, srcinfo.Source, &mux, &wg)
+			go updateVCSData(split.Name, srcinfos[pkg].Source, &mux, &wg)
 		}
 
 		wg.Wait()
+		return nil
+	}
+
+	if config.BuildJobs > 1 && len(do.Aur) > 1 {
+		err := buildInstallParallel(b, buildDir, dp, do, incompatible, installed, jr, summary, queueInstall, doInstall)
+		if err != nil {
+			return err
+		}
+	} else {
+		graph := do.aurDeps()
+		built := make(map[string]map[string]string)
+
+		for i, base := range do.Aur {
+			var err error
+			pkg := base.Pkgbase()
+
+			if blocker := summary.blockedBy(base); blocker != "" {
+				fmt.Println(bold(yellow(arrow)),
+					cyan(base.String())+bold(" depends on "+blocker+" which failed -- skipping"))
+				summary.skipped(base, blocker)
+				continue
+			}
+
+			satisfied := true
+		all:
+			for _, pkg := range base {
+				for _, deps := range buildDeps(pkg) {
+					for _, dep := range deps {
+						if _, err := dp.LocalDB.PkgCache().FindSatisfier(dep); err != nil {
+							satisfied = false
+							fmt.Printf("%s not satisfied, flushing install queue", dep)
+							break all
+						}
+					}
+				}
+			}
+
+			if !satisfied || !config.BatchInstall {
+				err = doInstall()
+				if err != nil {
+					return err
+				}
+			}
+
+			pkgdests, version, resumed := jr.built(base)
+			if resumed {
+				fmt.Println(bold(yellow(arrow)),
+					cyan(pkg+"-"+version)+bold(" built by a previous run -- skipping build"))
+			} else {
+				deps := depFiles(do, graph, i, built)
+				build := buildBase(b, buildDir, dp, base, deps, incompatible.get(pkg), installed, nil)
+				if build.err != nil {
+					if build.cmd == nil || !config.KeepGoing {
+						return build.err
+					}
+					fmt.Fprintln(os.Stderr, bold(red(arrow)), bold("Error making: "+base.String()+" -- continuing"))
+					summary.failed(base, build.cmd)
+					continue
+				}
+
+				if build.upToDate {
+					built[pkg] = build.pkgdests
+					jr.setInstalled(pkg)
+					summary.succeeded(base)
+					continue
+				}
+
+				pkgdests, version = build.pkgdests, build.version
+				jr.setBuilt(pkg, version, pkgdests)
+			}
+			built[pkg] = pkgdests
+
+			err = queueInstall(base, version, pkgdests)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	err := doInstall()
+	err = doInstall()
 	config.NoConfirm = oldConfirm
-	return err
+	if err != nil {
+		return err
+	}
+
+	if config.KeepGoing {
+		return summary.err()
+	}
+
+	return nil
+}
+
+// installedVersions returns the installed version of every package in do.Aur
+// so the builds can check --needed without going through alpm.
+func installedVersions(dp *depPool, do *depOrder) map[string]string {
+	installed := make(map[string]string)
+
+	for _, base := range do.Aur {
+		for _, pkg := range base {
+			if alpmpkg := dp.LocalDB.Pkg(pkg.Name); alpmpkg != nil {
+				installed[pkg.Name] = alpmpkg.Version()
+			}
+		}
+	}
+
+	return installed
+}
+
+// baseBuild is the outcome of buildBase.
+type baseBuild struct {
+	pkgdests map[string]string
+	version  string
+	upToDate bool
+	// the makepkg command that failed, nil if the error came from elsewhere
+	cmd *exec.Cmd
+	err error
+}
+
+// buildBase bumps the pkgver of a base and builds it unless a usable package
+// is already in the build dir. It only runs makepkg and never touches the alpm
+// handle so it can be called for multiple bases concurrently. When out is
+// non nil makepkg runs without stdin and all output goes to out.
+func buildBase(b builder, buildDir string, dp *depPool, base Base, deps []string, incompatible bool, installed map[string]string, out io.Writer) *baseBuild {
+	pkg := base.Pkgbase()
+	dir := filepath.Join(buildDir, pkg)
+	built := true
+
+	run := func(cmd *exec.Cmd) error {
+		return runLog(cmd, out, pkg)
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+
+	//pkgver bump
+	cmd := b.pkgver(dir, incompatible)
+	err := run(cmd)
+	if err != nil {
+		return &baseBuild{cmd: cmd, err: fmt.Errorf("Error making: %s", base.String())}
+	}
+
+	pkgdests, version, err := parsePackageList(dir)
+	if err != nil {
+		return &baseBuild{err: err}
+	}
+
+	isExplicit := false
+	for _, b := range base {
+		isExplicit = isExplicit || dp.Explicit.get(b.Name)
+	}
+	if config.ReBuild == "no" || (config.ReBuild == "yes" && !isExplicit) {
+		for _, split := range base {
+			pkgdest, ok := pkgdests[split.Name]
+			if !ok {
+				return &baseBuild{err: fmt.Errorf("Could not find PKGDEST for: %s", split.Name)}
+			}
+
+			_, err := os.Stat(pkgdest)
+			if os.IsNotExist(err) {
+				built = false
+			} else if err != nil {
+				return &baseBuild{err: err}
+			}
+		}
+	} else {
+		built = false
+	}
+
+	if cmdArgs.existsArg("needed") {
+		upToDate := true
+		for _, split := range base {
+			if installed[split.Name] != version {
+				upToDate = false
+			}
+		}
+
+		if upToDate {
+			run(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"))
+			fmt.Fprintln(out, cyan(pkg+"-"+version)+bold(" is up to date -- skipping"))
+			return &baseBuild{pkgdests, version, true, nil, nil}
+		}
+	}
+
+	if built {
+		run(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"))
+		fmt.Fprintln(out, bold(yellow(arrow)),
+			cyan(pkg+"-"+version)+bold(" already made -- skipping build"))
+	} else {
+		cmd := b.build(dir, deps, incompatible)
+		err := run(cmd)
+		if err != nil {
+			return &baseBuild{cmd: cmd, err: fmt.Errorf("Error making: %s", base.String())}
+		}
+	}
+
+	return &baseBuild{pkgdests, version, false, nil, nil}
 }
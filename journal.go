@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// journalFileName holds the name of the transaction journal.
+const journalFileName string = "transaction.json"
+
+// States an AUR base moves through while being built and installed.
+const (
+	baseDownloaded = "downloaded"
+	baseBuilt      = "built"
+	baseInstalled  = "installed"
+)
+
+// baseState tracks the progress of a single AUR base.
+type baseState struct {
+	State    string            `json:"state"`
+	Version  string            `json:"version,omitempty"`
+	Pkgdests map[string]string `json:"pkgdests,omitempty"`
+}
+
+// installJournal records an in progress AUR transaction so that it can be
+// picked up again with --resume after a failure.
+type installJournal struct {
+	Bases        []Base                `json:"bases"`
+	Explicit     []string              `json:"explicit"`
+	Runtime      []string              `json:"runtime"`
+	Make         []string              `json:"make"`
+	Incompatible []string              `json:"incompatible"`
+	Conflicts    map[string][]string   `json:"conflicts"`
+	Options      map[string]string     `json:"options"`
+	Globals      map[string]string     `json:"globals"`
+	RemoveMake   bool                  `json:"removemake"`
+	CleanAfter   bool                  `json:"cleanafter"`
+	States       map[string]*baseState `json:"states"`
+
+	path  string
+	saved bool
+}
+
+func journalPath() string {
+	return filepath.Join(cacheHome, journalFileName)
+}
+
+func makeInstallJournal(dp *depPool, do *depOrder, parser *arguments, conflicts mapStringSet) *installJournal {
+	parser = parser.copy()
+	jr := &installJournal{
+		make([]Base, 0, len(do.Aur)),
+		dp.Explicit.toSlice(),
+		do.Runtime.toSlice(),
+		do.getMake(),
+		make([]string, 0),
+		make(map[string][]string),
+		parser.options,
+		parser.globals,
+		false,
+		config.CleanAfter,
+		make(map[string]*baseState),
+		journalPath(),
+		false,
+	}
+
+	for _, base := range do.Aur {
+		jr.Bases = append(jr.Bases, base)
+		jr.States[base.Pkgbase()] = &baseState{State: baseDownloaded}
+	}
+
+	for name, pkgs := range conflicts {
+		jr.Conflicts[name] = pkgs.toSlice()
+	}
+
+	return jr
+}
+
+// loadInstallJournal reads the journal left behind by an interrupted install.
+func loadInstallJournal() (*installJournal, error) {
+	path := journalPath()
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no interrupted transaction to resume")
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to open transaction journal '%s': %s", path, err)
+	}
+	defer file.Close()
+
+	jr := &installJournal{}
+	if err = json.NewDecoder(file).Decode(jr); err != nil {
+		return nil, fmt.Errorf("Failed to read transaction journal '%s': %s", path, err)
+	}
+
+	if len(jr.Bases) == 0 || jr.States == nil {
+		return nil, fmt.Errorf("transaction journal '%s' is empty", path)
+	}
+
+	jr.path = path
+	jr.saved = true
+	return jr, nil
+}
+
+func (jr *installJournal) save() error {
+	if jr == nil {
+		return nil
+	}
+
+	marshalled, err := json.MarshalIndent(jr, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a half written journal
+	tmp := jr.path + ".tmp"
+	if err = writeFileSync(tmp, marshalled); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, jr.path); err != nil {
+		return err
+	}
+
+	jr.saved = true
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(data); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// remove deletes the journal once the transaction has completed.
+func (jr *installJournal) remove() {
+	if jr == nil || !jr.saved {
+		return
+	}
+
+	if err := os.Remove(jr.path); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	jr.saved = false
+}
+
+// pending reports whether the journal holds an unfinished transaction.
+func (jr *installJournal) pending() bool {
+	return jr != nil && jr.saved
+}
+
+// update sets the state of a base and writes the journal to disk. Failing to
+// write the journal only costs the ability to resume so it is not fatal.
+func (jr *installJournal) update(pkgbase string, state *baseState) {
+	if jr == nil {
+		return
+	}
+
+	jr.States[pkgbase] = state
+	if err := jr.save(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write transaction journal:", err)
+	}
+}
+
+func (jr *installJournal) setBuilt(pkgbase, version string, pkgdests map[string]string) {
+	jr.update(pkgbase, &baseState{baseBuilt, version, pkgdests})
+}
+
+func (jr *installJournal) setInstalled(pkgbase string) {
+	if jr == nil {
+		return
+	}
+
+	state := jr.States[pkgbase]
+	if state == nil {
+		state = &baseState{}
+	}
+	state.State = baseInstalled
+	jr.update(pkgbase, state)
+}
+
+// built returns the packages of a base built by a previous run, as long as
+// they are all still in place.
+func (jr *installJournal) built(base Base) (map[string]string, string, bool) {
+	if jr == nil {
+		return nil, "", false
+	}
+
+	state := jr.States[base.Pkgbase()]
+	if state == nil || state.State != baseBuilt {
+		return nil, "", false
+	}
+
+	for _, pkg := range base {
+		pkgdest, ok := state.Pkgdests[pkg.Name]
+		if !ok {
+			return nil, "", false
+		}
+		if _, err := os.Stat(pkgdest); err != nil {
+			return nil, "", false
+		}
+	}
+
+	return state.Pkgdests, state.Version, true
+}
+
+// resumeInstall continues the transaction recorded in the journal, skipping
+// every base that was already installed and reusing built packages.
+func resumeInstall() error {
+	jr, err := loadInstallJournal()
+	if err != nil {
+		return err
+	}
+
+	if os.Geteuid() == 0 {
+		return fmt.Errorf("%s Refusing to install AUR Packages as root, Aborting.", bold(red(arrow)))
+	}
+
+	dp, err := makeDepPool()
+	if err != nil {
+		return err
+	}
+
+	parser := makeArguments()
+	parser.op = "S"
+	for option, value := range jr.Options {
+		parser.options[option] = value
+	}
+	for option, value := range jr.Globals {
+		parser.globals[option] = value
+	}
+	// Parts of the build read the command line directly, restore the original
+	cmdArgs = parser
+
+	do := makeDepOrder()
+	for _, pkg := range jr.Runtime {
+		do.Runtime.set(pkg)
+	}
+	for _, pkg := range jr.Explicit {
+		dp.Explicit.set(pkg)
+	}
+
+	for _, base := range jr.Bases {
+		for _, pkg := range base {
+			dp.Aur[pkg.Name] = pkg
+		}
+
+		if state := jr.States[base.Pkgbase()]; state != nil && state.State == baseInstalled {
+			continue
+		}
+		do.Aur = append(do.Aur, base)
+	}
+
+	conflicts := make(mapStringSet)
+	for name, pkgs := range jr.Conflicts {
+		conflicts[name] = sliceToStringSet(pkgs)
+	}
+
+	defer startBuildLogs()()
+	defer startHistory()()
+
+	fmt.Println(bold(cyan("::")+" Resuming transaction:"), len(do.Aur), "of", len(jr.Bases), "AUR bases remaining")
+	do.Print()
+	fmt.Println()
+
+	srcinfos, err := parseSrcinfoFiles(do.Aur, true)
+	if err != nil {
+		return err
+	}
+
+	err = buildInstallPkgbuilds(config.BuildDir, dp, do, srcinfos, parser, sliceToStringSet(jr.Incompatible), conflicts, jr)
+	if err != nil {
+		printResumeHint()
+		return err
+	}
+
+	if jr.CleanAfter {
+		cleanAfter(jr.Bases)
+	}
+
+	if jr.RemoveMake {
+		err = removeMakeDeps(jr.Make)
+	}
+
+	jr.remove()
+	return err
+}
+
+func printResumeHint() {
+	fmt.Fprintln(os.Stderr, bold(yellow(arrow))+" Progress has been saved, run 'yay --resume' to continue the transaction")
+}
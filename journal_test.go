@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestInstallJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldConfig, oldCacheHome := config, cacheHome
+	config, cacheHome = defaultSettings(), dir
+	defer func() { config, cacheHome = oldConfig, oldCacheHome }()
+
+	lib := Base{{Name: "lib", PackageBase: "lib", Version: "1.0-1"}}
+	app := Base{{Name: "app", PackageBase: "app", Version: "2.0-1"}, {Name: "app-docs", PackageBase: "app", Version: "2.0-1"}}
+
+	dp := &depPool{Explicit: sliceToStringSet([]string{"app"}), Aur: make(map[string]*rpc.Pkg)}
+	do := makeDepOrder()
+	do.Aur = []Base{lib, app}
+	do.Runtime = sliceToStringSet([]string{"app", "app-docs"})
+
+	jr := makeInstallJournal(dp, do, makeArguments(), make(mapStringSet))
+	if jr.pending() {
+		t.Fatal("expected a new journal not to be pending")
+	}
+
+	pkgdest := filepath.Join(dir, "lib-1.0-1-x86_64.pkg.tar.xz")
+	if err = ioutil.WriteFile(pkgdest, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	jr.setBuilt("lib", "1.0-1", map[string]string{"lib": pkgdest})
+	if !jr.pending() {
+		t.Fatal("expected the journal to be pending once saved")
+	}
+
+	loaded, err := loadInstallJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Bases) != 2 || loaded.Bases[1].Pkgbase() != "app" || len(loaded.Bases[1]) != 2 {
+		t.Fatalf("expected bases [lib app] got %v", loaded.Bases)
+	}
+	if len(loaded.Make) != 1 || loaded.Make[0] != "lib" {
+		t.Errorf("expected make [lib] got %v", loaded.Make)
+	}
+
+	pkgdests, version, ok := loaded.built(lib)
+	if !ok || version != "1.0-1" || pkgdests["lib"] != pkgdest {
+		t.Errorf("expected lib-1.0-1 to be built got %v %s %t", pkgdests, version, ok)
+	}
+	if _, _, ok = loaded.built(app); ok {
+		t.Error("expected app not to be built")
+	}
+
+	// Packages removed since are built again
+	os.Remove(pkgdest)
+	if _, _, ok = loaded.built(lib); ok {
+		t.Error("expected lib not to be built once its package is gone")
+	}
+
+	loaded.setInstalled("lib")
+	if loaded.States["lib"].State != baseInstalled || loaded.States["lib"].Version != "1.0-1" {
+		t.Errorf("expected lib-1.0-1 to be installed got %v", loaded.States["lib"])
+	}
+
+	loaded.remove()
+	if loaded.pending() {
+		t.Error("expected the journal not to be pending once removed")
+	}
+	if _, err = loadInstallJournal(); err == nil {
+		t.Error("expected no journal to resume")
+	}
+	if _, err = os.Stat(journalPath() + ".tmp"); !os.IsNotExist(err) {
+		t.Error("expected the temporary file to be renamed")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// localRepo is a pacman repository on this machine that built AUR packages
+// are added to. It must be declared in pacman.conf with a file:// server.
+type localRepo struct {
+	name string
+	dir  string
+}
+
+// getLocalRepo returns the configured local repo or nil when none is set.
+func getLocalRepo() (*localRepo, error) {
+	if config.LocalRepo == "" {
+		return nil, nil
+	}
+
+	repo := pacmanConf.Repository(config.LocalRepo)
+	if repo == nil {
+		return nil, fmt.Errorf("local repo '%s' is not declared in %s", config.LocalRepo, config.PacmanConf)
+	}
+
+	for _, server := range repo.Servers {
+		if strings.HasPrefix(server, "file://") {
+			return &localRepo{repo.Name, strings.TrimPrefix(server, "file://")}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("local repo '%s' has no file:// server", config.LocalRepo)
+}
+
+func isLocalRepo(name string) bool {
+	return config.LocalRepo != "" && name == config.LocalRepo
+}
+
+func (repo *localRepo) dbFile() string {
+	return filepath.Join(repo.dir, repo.name+".db.tar.gz")
+}
+
+// add copies the package files into the repo and adds them to its database.
+// Older versions of the packages are removed from the repo.
+func (repo *localRepo) add(pkgdests []string, bases []string) error {
+	if err := os.MkdirAll(repo.dir, 0755); err != nil {
+		return fmt.Errorf("Failed to create local repo directory '%s': %s", repo.dir, err)
+	}
+
+	files := make([]string, 0, len(pkgdests))
+	for _, pkgdest := range pkgdests {
+		file := filepath.Join(repo.dir, filepath.Base(pkgdest))
+		files = append(files, file)
+
+		if filepath.Dir(pkgdest) == filepath.Clean(repo.dir) {
+			continue
+		}
+
+		if err := copyFile(pkgdest, file); err != nil {
+			return err
+		}
+		if _, err := os.Stat(pkgdest + ".sig"); err == nil {
+			if err = copyFile(pkgdest+".sig", file+".sig"); err != nil {
+				return err
+			}
+		}
+	}
+
+	args := append([]string{"-R", repo.dbFile()}, files...)
+	if err := showLog(exec.Command("repo-add", args...), bases...); err != nil {
+		return fmt.Errorf("Error adding packages to local repo: %s", repo.name)
+	}
+
+	return nil
+}
+
+// refreshConf returns a pacman.conf declaring the local repo and no other.
+func (repo *localRepo) refreshConf() string {
+	var conf strings.Builder
+
+	conf.WriteString("[options]\n")
+	fmt.Fprintf(&conf, "RootDir = %s\n", pacmanConf.RootDir)
+	fmt.Fprintf(&conf, "DBPath = %s\n", pacmanConf.DBPath)
+	if pacmanConf.GPGDir != "" {
+		fmt.Fprintf(&conf, "GPGDir = %s\n", pacmanConf.GPGDir)
+	}
+	if pacmanConf.Architecture != "" {
+		fmt.Fprintf(&conf, "Architecture = %s\n", pacmanConf.Architecture)
+	}
+	if len(pacmanConf.SigLevel) > 0 {
+		fmt.Fprintf(&conf, "SigLevel = %s\n", strings.Join(pacmanConf.SigLevel, " "))
+	}
+
+	fmt.Fprintf(&conf, "\n[%s]\n", repo.name)
+	if declared := pacmanConf.Repository(repo.name); declared != nil && len(declared.SigLevel) > 0 {
+		fmt.Fprintf(&conf, "SigLevel = %s\n", strings.Join(declared.SigLevel, " "))
+	}
+	fmt.Fprintf(&conf, "Server = file://%s\n", repo.dir)
+
+	return conf.String()
+}
+
+// refresh runs pacman -Sy with a pacman.conf declaring only the local repo.
+// Unlike a plain pacman -Sy this leaves every other database alone so
+// installing from the local repo can never cause a partial upgrade.
+func (repo *localRepo) refresh(bases []string) error {
+	conf, err := ioutil.TempFile("", "yay-localrepo")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(conf.Name())
+
+	_, err = conf.WriteString(repo.refreshConf())
+	conf.Close()
+	if err != nil {
+		return err
+	}
+
+	waitLock()
+	err = showLog(exec.Command("sudo", config.PacmanBin, "--config", conf.Name(), "-Sy"), bases...)
+	if err != nil {
+		return fmt.Errorf("Error refreshing local repo: %s", repo.name)
+	}
+
+	return nil
+}
+
+// install adds the package files queued for pacman -U to the repo, then
+// installs them from it with pacman -S instead. bases are the package bases
+// being installed, for logging.
+func (repo *localRepo) install(arguments *arguments, names []string, bases []string) error {
+	err := repo.add(arguments.targets, bases)
+	if err != nil {
+		return err
+	}
+
+	err = repo.refresh(bases)
+	if err != nil {
+		return err
+	}
+
+	syncArgs := arguments.copy()
+	syncArgs.op = "S"
+	syncArgs.clearTargets()
+	for _, name := range names {
+		syncArgs.addTarget(repo.name + "/" + name)
+	}
+
+	return showLog(passToPacman(syncArgs), bases...)
+}
+
+// clean removes package files from the repo directory that are no longer in
+// the repo database.
+func (repo *localRepo) clean() error {
+	dbList, err := alpmHandle.SyncDBs()
+	if err != nil {
+		return err
+	}
+
+	inDB := make(stringSet)
+	dbList.ForEach(func(db alpm.DB) error {
+		if db.Name() != repo.name {
+			return nil
+		}
+
+		return db.PkgCache().ForEach(func(pkg alpm.Package) error {
+			inDB.set(pkg.FileName())
+			return nil
+		})
+	})
+
+	files, err := ioutil.ReadDir(repo.dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("removing stale packages from", repo.name+"...")
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".sig")
+		if file.IsDir() || !strings.Contains(name, ".pkg.tar") || inDB.get(name) {
+			continue
+		}
+
+		if err = os.Remove(filepath.Join(repo.dir, file.Name())); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	return nil
+}
+
+// bases returns the package bases in the repo.
+func (repo *localRepo) bases() stringSet {
+	bases := make(stringSet)
+
+	dbList, err := alpmHandle.SyncDBs()
+	if err != nil {
+		return bases
+	}
+
+	dbList.ForEach(func(db alpm.DB) error {
+		if db.Name() != repo.name {
+			return nil
+		}
+
+		return db.PkgCache().ForEach(func(pkg alpm.Package) error {
+			if pkg.Base() != "" {
+				bases.set(pkg.Base())
+			} else {
+				bases.set(pkg.Name())
+			}
+			return nil
+		})
+	})
+
+	return bases
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Sync()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	pacmanconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+func TestLocalRepoRefreshConf(t *testing.T) {
+	old := pacmanConf
+	pacmanConf = &pacmanconf.Config{
+		RootDir:      "/",
+		DBPath:       "/var/lib/pacman/",
+		Architecture: "x86_64",
+		SigLevel:     []string{"Required", "DatabaseOptional"},
+		Repos: []pacmanconf.Repository{
+			{Name: "core", Servers: []string{"https://mirror/core"}},
+			{Name: "custom", SigLevel: []string{"Optional", "TrustAll"}},
+		},
+	}
+	defer func() { pacmanConf = old }()
+
+	repo := &localRepo{"custom", "/home/user/repo"}
+	expected := `[options]
+RootDir = /
+DBPath = /var/lib/pacman/
+Architecture = x86_64
+SigLevel = Required DatabaseOptional
+
+[custom]
+SigLevel = Optional TrustAll
+Server = file:///home/user/repo
+`
+	if conf := repo.refreshConf(); conf != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, conf)
+	}
+}
@@ -30,6 +30,8 @@ func setPaths() error {
 
 	configFile = filepath.Join(configHome, configFileName)
 	vcsFile = filepath.Join(cacheHome, vcsFileName)
+	holdsFile = filepath.Join(configHome, holdsFileName)
+	aurCacheFile = filepath.Join(cacheHome, aurCacheFileName)
 
 	return nil
 }
@@ -219,6 +221,8 @@ func main() {
 	config.expandEnv()
 	exitOnError(initBuildDir())
 	exitOnError(initVCS())
+	exitOnError(initHolds())
+	initHTTP()
 	exitOnError(initAlpm())
 	exitOnError(handleCmd())
 	os.Exit(cleanup())
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// prefixWriter prefixes every line written to it before passing it on to out.
+// Lines are written whole so output from concurrent builds does not mix.
+type prefixWriter struct {
+	out    io.Writer
+	prefix []byte
+	mux    *sync.Mutex
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, prefix string, mux *sync.Mutex) *prefixWriter {
+	return &prefixWriter{out, []byte(prefix), mux, nil}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out any trailing output not ended by a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mux.Lock()
+	w.out.Write(w.prefix)
+	w.out.Write(line)
+	w.mux.Unlock()
+}
+
+// States of a base while building in parallel.
+const (
+	jobPending = iota
+	jobRunning
+	jobQueued
+	jobInstalled
+	jobBroken
+)
+
+type jobResult struct {
+	index int
+	build *baseBuild
+}
+
+// buildInstallParallel builds up to config.BuildJobs bases of do.Aur at the
+// same time. A base starts once every base it depends on is installed. Only
+// the makepkg runs are concurrent, the journal, the install queue and
+// everything touching alpm stay on the calling goroutine. Built bases are
+// installed straight away when a pending base needs them, otherwise they are
+// left in the queue for the caller to install.
+func buildInstallParallel(b builder, buildDir string, dp *depPool, do *depOrder, incompatible stringSet, installed map[string]string,
+	jr *installJournal, summary *buildSummary,
+	queueInstall func(Base, string, map[string]string) error, doInstall func() error) error {
+	graph := do.aurDeps()
+	built := make(map[string]map[string]string)
+	state := make([]int, len(do.Aur))
+	results := make(chan jobResult)
+	running := 0
+	var firstErr error
+	var mux sync.Mutex
+
+	neededBy := func(i int) bool {
+		for j, parents := range graph {
+			if state[j] != jobPending {
+				continue
+			}
+			for _, parent := range parents {
+				if parent == i {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	// Output of the calling goroutine takes the same lock as the builders so
+	// it is not interleaved with their lines. The lock is held while pacman
+	// runs, holding back builder output while it prompts.
+	printLine := func(w io.Writer, a ...interface{}) {
+		mux.Lock()
+		fmt.Fprintln(w, a...)
+		mux.Unlock()
+	}
+
+	install := func() error {
+		mux.Lock()
+		err := doInstall()
+		mux.Unlock()
+		if err != nil {
+			return err
+		}
+
+		// With --keepgoing a failed install is recorded in the summary
+		// instead of returned
+		for i := range state {
+			if state[i] == jobQueued {
+				state[i] = jobInstalled
+				if summary.failedBase(do.Aur[i]) {
+					state[i] = jobBroken
+				}
+			}
+		}
+
+		return nil
+	}
+
+	finish := func(i int, build *baseBuild) {
+		base := do.Aur[i]
+		pkg := base.Pkgbase()
+
+		if build.err != nil {
+			state[i] = jobBroken
+			if build.cmd == nil || !config.KeepGoing {
+				if firstErr == nil {
+					firstErr = build.err
+				}
+				return
+			}
+
+			printLine(os.Stderr, bold(red(arrow)), bold("Error making: "+base.String()+" -- continuing"))
+			summary.failed(base, build.cmd)
+			return
+		}
+
+		built[pkg] = build.pkgdests
+		if build.upToDate {
+			state[i] = jobInstalled
+			jr.setInstalled(pkg)
+			summary.succeeded(base)
+			return
+		}
+
+		jr.setBuilt(pkg, build.version, build.pkgdests)
+
+		// Keep what was built for --resume but install nothing more
+		if firstErr != nil {
+			state[i] = jobBroken
+			return
+		}
+
+		if err := queueInstall(base, build.version, build.pkgdests); err != nil {
+			state[i] = jobBroken
+			firstErr = err
+			return
+		}
+		state[i] = jobQueued
+
+		if !config.BatchInstall || neededBy(i) {
+			if err := install(); err != nil {
+				firstErr = err
+			}
+		}
+	}
+
+	start := func(i int) {
+		base := do.Aur[i]
+		pkg := base.Pkgbase()
+
+		if pkgdests, version, ok := jr.built(base); ok {
+			printLine(os.Stdout, bold(yellow(arrow)),
+				cyan(pkg+"-"+version)+bold(" built by a previous run -- skipping build"))
+			finish(i, &baseBuild{pkgdests, version, false, nil, nil})
+			return
+		}
+
+		printLine(os.Stdout, bold(cyan("::")), bold("Building"), cyan(base.String()))
+		state[i] = jobRunning
+		running++
+
+		deps := depFiles(do, graph, i, built)
+		out := newPrefixWriter(os.Stdout, cyan("["+pkg+"]")+" ", &mux)
+		go func() {
+			build := buildBase(b, buildDir, dp, base, deps, incompatible.get(pkg), installed, out)
+			out.Flush()
+			results <- jobResult{i, build}
+		}()
+	}
+
+	// ready reports whether base i can start. blocker is set when a base it
+	// depends on failed.
+	ready := func(i int) (ok bool, blocker string) {
+		ok = true
+		for _, parent := range graph[i] {
+			switch state[parent] {
+			case jobInstalled:
+			case jobBroken:
+				return false, do.Aur[parent].Pkgbase()
+			default:
+				ok = false
+			}
+		}
+
+		return ok, ""
+	}
+
+	for {
+		started := false
+
+		for i := 0; firstErr == nil && i < len(do.Aur) && running < config.BuildJobs; i++ {
+			if state[i] != jobPending {
+				continue
+			}
+
+			ok, blocker := ready(i)
+			if blocker != "" {
+				printLine(os.Stdout, bold(yellow(arrow)),
+					cyan(do.Aur[i].String())+bold(" depends on "+blocker+" which failed -- skipping"))
+				state[i] = jobBroken
+				summary.skipped(do.Aur[i], blocker)
+				started = true
+			} else if ok {
+				start(i)
+				started = true
+			}
+		}
+
+		if started {
+			continue
+		}
+
+		if running == 0 {
+			// Dependencies between split packages can form a loop the graph
+			// can not order. Fall back to building in order like a serial
+			// build would.
+			next := -1
+			for i := range state {
+				if state[i] == jobPending {
+					next = i
+					break
+				}
+			}
+
+			if next == -1 || firstErr != nil {
+				break
+			}
+
+			if err := install(); err != nil {
+				return err
+			}
+			start(next)
+			continue
+		}
+
+		result := <-results
+		running--
+		finish(result.index, result.build)
+	}
+
+	return firstErr
+}
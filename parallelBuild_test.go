@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var mux sync.Mutex
+	w := newPrefixWriter(&buf, "[foo] ", &mux)
+
+	w.Write([]byte("a\nb"))
+	w.Write([]byte("c\n\nd\n"))
+	w.Write([]byte("e"))
+	if expected := "[foo] a\n[foo] bc\n[foo] \n[foo] d\n"; buf.String() != expected {
+		t.Fatalf("expected %q got %q", expected, buf.String())
+	}
+
+	w.Flush()
+	w.Flush()
+	if expected := "[foo] a\n[foo] bc\n[foo] \n[foo] d\n[foo] e\n"; buf.String() != expected {
+		t.Fatalf("expected %q got %q", expected, buf.String())
+	}
+}
+
+func TestPrefixWriterConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, prefix := range []string{"[foo] ", "[bar] "} {
+		wg.Add(1)
+		go func(w *prefixWriter) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				// Split mid line so only whole lines keep the output intact
+				w.Write([]byte("first half "))
+				w.Write([]byte("second half\n"))
+			}
+		}(newPrefixWriter(&buf, prefix, &mux))
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("expected 200 lines got %d", len(lines))
+	}
+	for _, line := range lines {
+		if line != "[foo] first half second half" && line != "[bar] first half second half" {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
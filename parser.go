@@ -155,6 +155,9 @@ func (parser *arguments) needRoot() bool {
 		}
 		return true
 	case "S", "sync":
+		if config.PrintPlan != "" || parser.existsArg("why", "graph") {
+			return false
+		}
 		if parser.existsArg("y", "refresh") {
 			return true
 		}
@@ -179,6 +182,11 @@ func (parser *arguments) needRoot() bool {
 		return true
 	case "U", "upgrade":
 		return true
+	case "Y", "yay":
+		if parser.existsArg("resume", "rollback", "downgrade") {
+			return true
+		}
+		return false
 	default:
 		return false
 	}
@@ -451,6 +459,29 @@ func isArg(arg string) bool {
 	case "norebuild":
 	case "batchinstall":
 	case "nobatchinstall":
+	case "keepgoing", "keep-going":
+	case "nokeepgoing", "no-keep-going":
+	case "buildjobs", "build-jobs":
+	case "chroot":
+	case "nochroot":
+	case "localrepo":
+	case "nolocalrepo":
+	case "buildlogs":
+	case "nobuildlogs":
+	case "logretention":
+	case "aurcachettl":
+	case "aurmetadata":
+	case "noaurmetadata":
+	case "requesttimeout":
+	case "requestretries":
+	case "offline":
+	case "provider":
+	case "srcinfodeps":
+	case "nosrcinfodeps":
+	case "abortcycle":
+	case "noabortcycle":
+	case "brokencheck":
+	case "nobrokencheck":
 	case "answerclean":
 	case "noanswerclean":
 	case "answerdiff":
@@ -502,7 +533,22 @@ func isArg(arg string) bool {
 	case "stats":
 	case "news":
 	case "gendb":
+	case "resume":
+	case "history":
+	case "rollback":
+	case "downgrade":
+	case "hold":
+	case "unhold":
+	case "holds":
 	case "currentconfig":
+	case "print-plan", "printplan":
+	case "format":
+	case "logs":
+	case "broken":
+	case "why":
+	case "graph":
+	case "by":
+	case "maintainer":
 	default:
 		return false
 	}
@@ -561,6 +607,56 @@ func handleConfig(option, value string) bool {
 		config.BatchInstall = true
 	case "nobatchinstall":
 		config.BatchInstall = false
+	case "keepgoing", "keep-going":
+		config.KeepGoing = true
+	case "nokeepgoing", "no-keep-going":
+		config.KeepGoing = false
+	case "buildjobs", "build-jobs":
+		n, err := strconv.Atoi(value)
+		if err == nil && n > 0 {
+			config.BuildJobs = n
+		}
+	case "chroot":
+		config.UseChroot = true
+		if value != "" {
+			config.ChrootDir = value
+		}
+	case "nochroot":
+		config.UseChroot = false
+	case "localrepo":
+		config.LocalRepo = value
+	case "nolocalrepo":
+		config.LocalRepo = ""
+	case "buildlogs":
+		config.BuildLogs = true
+	case "nobuildlogs":
+		config.BuildLogs = false
+	case "logretention":
+		n, err := strconv.Atoi(value)
+		if err == nil && n >= 0 {
+			config.LogRetention = n
+		}
+	case "aurcachettl":
+		n, err := strconv.Atoi(value)
+		if err == nil && n >= 0 {
+			config.AURCacheTTL = n
+		}
+	case "aurmetadata":
+		config.AURMetadata = true
+	case "noaurmetadata":
+		config.AURMetadata = false
+	case "requesttimeout":
+		n, err := strconv.Atoi(value)
+		if err == nil && n > 0 {
+			config.RequestTimeout = n
+		}
+	case "requestretries":
+		n, err := strconv.Atoi(value)
+		if err == nil && n >= 0 {
+			config.RequestRetries = n
+		}
+	case "offline":
+		config.Offline = true
 	case "answerclean":
 		config.AnswerClean = value
 	case "noanswerclean":
@@ -616,6 +712,25 @@ func handleConfig(option, value string) bool {
 		config.SudoLoop = true
 	case "nosudoloop":
 		config.SudoLoop = false
+	case "srcinfodeps":
+		config.SrcinfoDeps = true
+	case "nosrcinfodeps":
+		config.SrcinfoDeps = false
+	case "abortcycle":
+		config.AbortCycle = true
+	case "noabortcycle":
+		config.AbortCycle = false
+	case "brokencheck":
+		config.BrokenCheck = true
+	case "nobrokencheck":
+		config.BrokenCheck = false
+	case "provider":
+		if config.Providers == nil {
+			config.Providers = make(providerPrefs)
+		}
+		for pattern, pkg := range parseProviderPrefs(value) {
+			config.Providers[pattern] = pkg
+		}
 	case "provides":
 		config.Provides = true
 	case "noprovides":
@@ -658,6 +773,13 @@ func handleConfig(option, value string) bool {
 		config.RemoveMake = "no"
 	case "askremovemake":
 		config.RemoveMake = "ask"
+	case "print-plan", "printplan":
+		config.PrintPlan = value
+		if value == "" {
+			config.PrintPlan = "human"
+		}
+	case "format":
+		config.Format = value
 	default:
 		return false
 	}
@@ -741,6 +863,18 @@ func hasParam(arg string) bool {
 	case "git":
 	case "gpg":
 	case "requestsplitn":
+	case "buildjobs", "build-jobs":
+	case "localrepo":
+	case "logretention":
+	case "aurcachettl":
+	case "requesttimeout":
+	case "requestretries":
+	case "provider":
+	case "rollback":
+	case "why":
+	case "by":
+	case "maintainer":
+	case "format":
 	case "answerclean":
 	case "answerdiff":
 	case "answeredit":
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// planPackage describes a single package in a transaction plan.
+type planPackage struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository,omitempty"`
+	Explicit   bool   `json:"explicit"`
+	Make       bool   `json:"make"`
+}
+
+// planBase describes an AUR package base in a transaction plan.
+type planBase struct {
+	Pkgbase  string        `json:"pkgbase"`
+	Version  string        `json:"version"`
+	Packages []planPackage `json:"packages"`
+}
+
+// transactionPlan is the result of resolving an install without performing it.
+type transactionPlan struct {
+	Repo      []planPackage       `json:"repo"`
+	Aur       []planBase          `json:"aur"`
+	Make      []string            `json:"make"`
+	Conflicts map[string][]string `json:"conflicts"`
+	Warnings  *aurWarnings        `json:"warnings"`
+}
+
+func makeTransactionPlan(dp *depPool, do *depOrder, conflicts mapStringSet, warnings *aurWarnings) *transactionPlan {
+	plan := &transactionPlan{
+		make([]planPackage, 0, len(do.Repo)),
+		make([]planBase, 0, len(do.Aur)),
+		do.getMake(),
+		make(map[string][]string),
+		warnings,
+	}
+
+	for _, pkg := range do.Repo {
+		plan.Repo = append(plan.Repo, planPackage{
+			pkg.Name(),
+			pkg.Version(),
+			pkg.DB().Name(),
+			dp.Explicit.get(pkg.Name()),
+			!do.Runtime.get(pkg.Name()),
+		})
+	}
+
+	for _, base := range do.Aur {
+		pb := planBase{
+			base.Pkgbase(),
+			base.Version(),
+			make([]planPackage, 0, len(base)),
+		}
+
+		for _, pkg := range base {
+			pb.Packages = append(pb.Packages, planPackage{
+				pkg.Name,
+				pkg.Version,
+				"aur",
+				dp.Explicit.get(pkg.Name),
+				!do.Runtime.get(pkg.Name),
+			})
+		}
+
+		plan.Aur = append(plan.Aur, pb)
+	}
+
+	for name, pkgs := range conflicts {
+		removes := pkgs.toSlice()
+		sort.Strings(removes)
+		plan.Conflicts[name] = removes
+	}
+
+	for _, list := range []*[]string{&warnings.Missing, &warnings.Orphans, &warnings.OutOfDate} {
+		if *list == nil {
+			*list = make([]string, 0)
+		}
+	}
+
+	return plan
+}
+
+// printTransactionPlan writes the plan to out in the requested format.
+func printTransactionPlan(out io.Writer, format string, dp *depPool, do *depOrder, conflicts mapStringSet, warnings *aurWarnings) error {
+	plan := makeTransactionPlan(dp, do, conflicts, warnings)
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		return enc.Encode(plan)
+	case "human":
+		plan.print(do)
+		return nil
+	}
+
+	return fmt.Errorf("invalid plan format '%s', expected human or json", format)
+}
+
+func (plan *transactionPlan) print(do *depOrder) {
+	fmt.Println()
+	fmt.Println(bold(cyan("::") + bold(" Transaction plan")))
+	do.Print()
+
+	if len(plan.Aur) > 0 {
+		fmt.Println(bold(cyan("::") + bold(" AUR build order:")))
+		for n, base := range do.Aur {
+			fmt.Println(magenta(fmt.Sprintf("%4d", n+1)), bold(base.String()), cyan(base.Version()))
+		}
+	}
+
+	if len(plan.Make) > 0 {
+		printDownloads("Make Only", len(plan.Make), "  "+strings.Join(plan.Make, "  "))
+	}
+
+	if len(plan.Conflicts) > 0 {
+		names := make([]string, 0, len(plan.Conflicts))
+		for name := range plan.Conflicts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println(bold(red(arrow)), bold("Conflicts:"))
+		for _, name := range names {
+			pkgs := plan.Conflicts[name]
+			str := red(bold(smallArrow)) + " " + cyan(name)
+			if len(pkgs) > 0 {
+				str += " removes: " + strings.Join(pkgs, "  ")
+			}
+			fmt.Println(str)
+		}
+	}
+
+	plan.Warnings.print()
+	fmt.Println(bold(cyan("::")) + bold(" "+strconv.Itoa(len(plan.Repo))+" repo and "+
+		strconv.Itoa(len(plan.Aur))+" AUR bases planned -- nothing was installed"))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestPrintTransactionPlanJSON(t *testing.T) {
+	lib := &rpc.Pkg{Name: "lib", PackageBase: "lib", Version: "1.0-1"}
+	app := &rpc.Pkg{Name: "app", PackageBase: "app", Version: "2.0-1"}
+	appDocs := &rpc.Pkg{Name: "app-docs", PackageBase: "app", Version: "2.0-1"}
+
+	dp := &depPool{Explicit: sliceToStringSet([]string{"app"})}
+	do := makeDepOrder()
+	do.Aur = []Base{{lib}, {app, appDocs}}
+	do.Runtime = sliceToStringSet([]string{"app", "app-docs"})
+
+	conflicts := make(mapStringSet)
+	conflicts.Add("app", "app-git")
+	conflicts.Add("app", "app-bin")
+
+	var buf bytes.Buffer
+	if err := printTransactionPlan(&buf, "json", dp, do, conflicts, &aurWarnings{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var plan struct {
+		Repo      []planPackage       `json:"repo"`
+		Aur       []planBase          `json:"aur"`
+		Make      []string            `json:"make"`
+		Conflicts map[string][]string `json:"conflicts"`
+		Warnings  map[string][]string `json:"warnings"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.Repo == nil || len(plan.Repo) != 0 {
+		t.Errorf("expected an empty repo list got %v", plan.Repo)
+	}
+	if len(plan.Aur) != 2 || plan.Aur[0].Pkgbase != "lib" || plan.Aur[1].Pkgbase != "app" {
+		t.Fatalf("expected bases [lib app] got %v", plan.Aur)
+	}
+	if pkgs := plan.Aur[1].Packages; len(pkgs) != 2 || !pkgs[0].Explicit || pkgs[1].Explicit || pkgs[0].Repository != "aur" {
+		t.Errorf("unexpected packages for app %v", pkgs)
+	}
+	if !plan.Aur[0].Packages[0].Make || len(plan.Make) != 1 || plan.Make[0] != "lib" {
+		t.Errorf("expected lib to be make only got %v", plan.Make)
+	}
+	if removes := plan.Conflicts["app"]; len(removes) != 2 || removes[0] != "app-bin" || removes[1] != "app-git" {
+		t.Errorf("expected sorted conflicts [app-bin app-git] got %v", removes)
+	}
+	for _, key := range []string{"missing", "orphans", "outOfDate"} {
+		if list, ok := plan.Warnings[key]; !ok || list == nil {
+			t.Errorf("expected warnings %s to be an empty list", key)
+		}
+	}
+
+	if err := printTransactionPlan(&buf, "yaml", dp, do, conflicts, &aurWarnings{}); err == nil {
+		t.Errorf("expected an error for an invalid format")
+	}
+}
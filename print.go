@@ -6,8 +6,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -43,6 +43,22 @@ func (warnings *aurWarnings) print() {
 		fmt.Println()
 	}
 
+	if len(warnings.Stale) > 0 {
+		fmt.Print(bold(yellow(smallArrow)) + " Stale Cached AUR Packages:")
+		for _, name := range warnings.Stale {
+			fmt.Print("  " + cyan(name))
+		}
+		fmt.Println()
+	}
+
+	if len(warnings.Uncached) > 0 {
+		fmt.Print(bold(yellow(smallArrow)) + " AUR Packages Missing From The Cache:")
+		for _, name := range warnings.Uncached {
+			fmt.Print("  " + cyan(name))
+		}
+		fmt.Println()
+	}
+
 }
 
 // human method returns results in human readable format.
@@ -91,6 +107,10 @@ func (q aurQuery) printSearch(start int) {
 			toprint += bold(red("(Out-of-date "+formatTime(res.OutOfDate)+")")) + " "
 		}
 
+		if _, stale := loadAURCache().staleSince(res.Name); stale {
+			toprint += bold(red("(Stale)")) + " "
+		}
+
 		if pkg := localDB.Pkg(res.Name); pkg != nil {
 			if pkg.Version() != res.Version {
 				toprint += bold(green("(Installed: " + pkg.Version() + ")"))
@@ -313,6 +333,10 @@ func PrintInfo(a *rpc.Pkg) {
 		printInfoValue("Out-of-date", "No")
 	}
 
+	if fetched, stale := loadAURCache().staleSince(a.Name); stale {
+		printInfoValue("Cached", red(formatTimeQuery(int(fetched.Unix()))+" (stale)"))
+	}
+
 	if cmdArgs.existsDouble("i") {
 		printInfoValue("ID", fmt.Sprintf("%d", a.ID))
 		printInfoValue("Package Base ID", fmt.Sprintf("%d", a.PackageBaseID))
@@ -350,11 +374,40 @@ func localStatistics() error {
 		return err
 	}
 
-	_, _, _, remoteNames, err := filterPackages()
+	_, remote, _, remoteNames, err := filterPackages()
 	if err != nil {
 		return err
 	}
 
+	migrations, err := findRepoMigrations(remote)
+	if err != nil {
+		return err
+	}
+
+	if config.Format != "" {
+		warnings := &aurWarnings{}
+		if _, err = aurInfo(remoteNames, warnings); err != nil {
+			return err
+		}
+		for _, list := range []*[]string{&warnings.Missing, &warnings.Orphans, &warnings.OutOfDate} {
+			if *list == nil {
+				*list = make([]string, 0)
+			}
+		}
+
+		stats := formatStats{
+			version,
+			info.Totaln,
+			len(remoteNames),
+			info.Expln,
+			info.TotalSize,
+			biggestFormatPkgs(10),
+			migrations,
+			warnings,
+		}
+		return printFormatted(os.Stdout, config.Format, stats)
+	}
+
 	fmt.Printf(bold("Yay version v%s\n"), version)
 	fmt.Println(bold(cyan("===========================================")))
 	fmt.Println(bold(green("Total installed packages: ")) + cyan(strconv.Itoa(info.Totaln)))
@@ -366,6 +419,14 @@ func localStatistics() error {
 	biggestPackages()
 	fmt.Println(bold(cyan("===========================================")))
 
+	if len(migrations) > 0 {
+		fmt.Println(bold(green("Foreign packages now in the repos:")))
+		for _, m := range migrations {
+			fmt.Printf("%s %s -> %s %s (%s)\n", bold(m.Name), green(m.Version), bold(m.Repository+"/"+m.RepoName), green(m.RepoVersion), m.Reason)
+		}
+		fmt.Println(bold(cyan("===========================================")))
+	}
+
 	aurInfoPrint(remoteNames)
 
 	return nil
@@ -382,6 +443,10 @@ func printNumberOfUpdates() error {
 	if err != nil {
 		return err
 	}
+
+	if config.Format != "" {
+		return printFormatted(os.Stdout, config.Format, updateCount{len(repoUp), len(aurUp), len(aurUp) + len(repoUp)})
+	}
 	fmt.Println(len(aurUp) + len(repoUp))
 
 	return nil
@@ -405,11 +470,14 @@ func printUpdateList(parser *arguments) error {
 	}
 
 	noTargets := len(targets) == 0
+	records := make([]formatPkg, 0)
 
 	if !parser.existsArg("m", "foreign") {
 		for _, pkg := range repoUp {
 			if noTargets || targets.get(pkg.Name) {
-				if parser.existsArg("q", "quiet") {
+				if config.Format != "" {
+					records = append(records, upgradeFormatPkg(pkg))
+				} else if parser.existsArg("q", "quiet") {
 					fmt.Printf("%s\n", pkg.Name)
 				} else {
 					fmt.Printf("%s %s -> %s\n", bold(pkg.Name), green(pkg.LocalVersion), green(pkg.RemoteVersion))
@@ -422,7 +490,9 @@ func printUpdateList(parser *arguments) error {
 	if !parser.existsArg("n", "native") {
 		for _, pkg := range aurUp {
 			if noTargets || targets.get(pkg.Name) {
-				if parser.existsArg("q", "quiet") {
+				if config.Format != "" {
+					records = append(records, upgradeFormatPkg(pkg))
+				} else if parser.existsArg("q", "quiet") {
 					fmt.Printf("%s\n", pkg.Name)
 				} else {
 					fmt.Printf("%s %s -> %s\n", bold(pkg.Name), green(pkg.LocalVersion), green(pkg.RemoteVersion))
@@ -432,6 +502,12 @@ func printUpdateList(parser *arguments) error {
 		}
 	}
 
+	if config.Format != "" {
+		if err = printFormatted(os.Stdout, config.Format, records); err != nil {
+			return err
+		}
+	}
+
 	missing := false
 
 outer:
@@ -459,6 +535,60 @@ outer:
 	return nil
 }
 
+// maintainedBy returns the packages of pkgs maintained by maintainer.
+func maintainedBy(pkgs []*rpc.Pkg, maintainer string) []*rpc.Pkg {
+	maintained := make([]*rpc.Pkg, 0)
+	for _, pkg := range pkgs {
+		if strings.EqualFold(pkg.Maintainer, maintainer) {
+			maintained = append(maintained, pkg)
+		}
+	}
+
+	return maintained
+}
+
+// printMaintainedBy prints the installed foreign packages the AUR lists as
+// maintained by maintainer.
+func printMaintainedBy(parser *arguments, maintainer string) error {
+	_, remote, _, remoteNames, err := filterPackages()
+	if err != nil {
+		return err
+	}
+
+	info, err := aurInfo(remoteNames, &aurWarnings{})
+	if err != nil {
+		return err
+	}
+
+	installed := make(map[string]string, len(remote))
+	for _, pkg := range remote {
+		installed[pkg.Name()] = pkg.Version()
+	}
+
+	maintained := maintainedBy(info, maintainer)
+	sort.Slice(maintained, func(i, j int) bool { return maintained[i].Name < maintained[j].Name })
+
+	if config.Format != "" {
+		records := make([]formatPkg, 0, len(maintained))
+		for _, pkg := range maintained {
+			record := aurFormatPkg(pkg)
+			record.Installed = installed[pkg.Name]
+			records = append(records, record)
+		}
+		return printFormatted(os.Stdout, config.Format, records)
+	}
+
+	for _, pkg := range maintained {
+		if parser.existsArg("q", "quiet") {
+			fmt.Println(pkg.Name)
+		} else {
+			fmt.Println(bold(pkg.Name), bold(green(installed[pkg.Name])))
+		}
+	}
+
+	return nil
+}
+
 type item struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
@@ -505,7 +635,7 @@ type rss struct {
 }
 
 func printNewsFeed() error {
-	resp, err := http.Get("https://archlinux.org/feeds/news")
+	resp, err := httpGet("https://archlinux.org/feeds/news")
 	if err != nil {
 		return err
 	}
@@ -529,6 +659,11 @@ func printNewsFeed() error {
 		return err
 	}
 
+	if config.Format != "" {
+		_, double, _ := cmdArgs.getArg("news", "w")
+		return printFormatted(os.Stdout, config.Format, formatNews(rss.Channel.Items, buildTime, double))
+	}
+
 	if config.SortMode == bottomUp {
 		for i := len(rss.Channel.Items) - 1; i >= 0; i-- {
 			rss.Channel.Items[i].print(buildTime)
@@ -615,7 +750,9 @@ func colourHash(name string) (output string) {
 	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", hash%6+31, name)
 }
 
-func providerMenu(dep string, providers providers) *rpc.Pkg {
+// providerMenu asks which of providers to use for dep, def is the index of
+// the provider picked by default.
+func providerMenu(dep string, providers providers, def int) *rpc.Pkg {
 	size := providers.Len()
 
 	fmt.Print(bold(cyan(":: ")))
@@ -632,11 +769,11 @@ func providerMenu(dep string, providers providers) *rpc.Pkg {
 	fmt.Fprintln(os.Stderr, str)
 
 	for {
-		fmt.Print("\nEnter a number (default=1): ")
+		fmt.Printf("\nEnter a number (default=%d): ", def+1)
 
 		if config.NoConfirm {
-			fmt.Println("1")
-			return providers.Pkgs[0]
+			fmt.Println(def + 1)
+			return providers.Pkgs[def]
 		}
 
 		reader := bufio.NewReader(os.Stdin)
@@ -653,7 +790,7 @@ func providerMenu(dep string, providers providers) *rpc.Pkg {
 		}
 
 		if string(numberBuf) == "" {
-			return providers.Pkgs[0]
+			return providers.Pkgs[def]
 		}
 
 		num, err := strconv.Atoi(string(numberBuf))
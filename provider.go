@@ -0,0 +1,123 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// providerNever used as a preference means the packages matching the pattern
+// are never picked to provide a dependency.
+const providerNever = "never"
+
+// providerPrefs maps a dependency, or a glob matching dependencies, to the
+// package that should provide it. A pattern mapped to "never" is matched
+// against package names instead, those packages only satisfy dependencies on
+// their own name.
+type providerPrefs map[string]string
+
+// parseProviderPrefs parses a comma separated list of dep=pkg preferences.
+// Malformed entries are skipped.
+func parseProviderPrefs(value string) providerPrefs {
+	prefs := make(providerPrefs)
+
+	for _, pref := range strings.Split(value, ",") {
+		split := strings.SplitN(pref, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		pattern := strings.TrimSpace(split[0])
+		pkg := strings.TrimSpace(split[1])
+		if pattern == "" || pkg == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			continue
+		}
+
+		prefs[pattern] = pkg
+	}
+
+	return prefs
+}
+
+// patterns returns the patterns of the preferences, sorted so that a choice
+// does not depend on map order.
+func (prefs providerPrefs) patterns() []string {
+	patterns := make([]string, 0, len(prefs))
+	for pattern := range prefs {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+// preferred returns the package that should provide dep, or an empty string
+// when there is no preference. A preference for the exact dependency name
+// wins over a glob.
+func (prefs providerPrefs) preferred(dep string) string {
+	if pkg, ok := prefs[dep]; ok && pkg != providerNever {
+		return pkg
+	}
+
+	for _, pattern := range prefs.patterns() {
+		pkg := prefs[pattern]
+		if pkg == providerNever {
+			continue
+		}
+		if match, _ := path.Match(pattern, dep); match {
+			return pkg
+		}
+	}
+
+	return ""
+}
+
+// allowed reports whether the package name may be picked to provide dep.
+func (prefs providerPrefs) allowed(dep string, name string) bool {
+	if name == dep {
+		return true
+	}
+
+	for pattern, pkg := range prefs {
+		if pkg != providerNever {
+			continue
+		}
+		if match, _ := path.Match(pattern, name); match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// choose picks the provider of dep out of names. It returns the index of the
+// provider to use by default and whether the choice is settled, in which case
+// the user should not be asked.
+func (prefs providerPrefs) choose(dep string, names []string) (int, bool) {
+	if pkg := prefs.preferred(dep); pkg != "" {
+		for i, name := range names {
+			if name == pkg {
+				return i, true
+			}
+		}
+	}
+
+	allowed := make([]int, 0, len(names))
+	for i, name := range names {
+		if prefs.allowed(dep, name) {
+			allowed = append(allowed, i)
+		}
+	}
+
+	switch len(allowed) {
+	case 0:
+		return 0, false
+	case 1:
+		return allowed[0], true
+	}
+
+	return allowed[0], false
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestProviderPrefsChoose(t *testing.T) {
+	prefs := parseProviderPrefs("java-environment=jdk8-openjdk, java-*=jdk-openjdk,*-git=never,bad,=x,[=y")
+
+	if len(prefs) != 3 {
+		t.Fatalf("Expected 3 preferences got %v", prefs)
+	}
+
+	type result struct {
+		Index   int
+		Settled bool
+	}
+
+	inputs := []struct {
+		Dep   string
+		Names []string
+	}{
+		{"java-environment", []string{"jdk-openjdk", "jdk8-openjdk"}},
+		{"java-runtime", []string{"jdk8-openjdk", "jdk-openjdk"}},
+		{"java-environment", []string{"jdk-openjdk", "jdk11-openjdk"}},
+		{"foo", []string{"foo-git", "foo-bin"}},
+		{"foo", []string{"foo-git", "foo-bin", "foo-hg"}},
+		{"foo-git", []string{"foo-git", "foo-bin"}},
+		{"foo", []string{"foo-git", "bar-git"}},
+	}
+
+	expected := []result{
+		{1, true},
+		{1, true},
+		{0, false},
+		{1, true},
+		{1, false},
+		{0, false},
+		{0, false},
+	}
+
+	for n, in := range inputs {
+		index, settled := prefs.choose(in.Dep, in.Names)
+		if index != expected[n].Index || settled != expected[n].Settled {
+			t.Fatalf("Test %d Failed: Expected: %d %t got %d %t", n+1, expected[n].Index, expected[n].Settled, index, settled)
+		}
+	}
+}
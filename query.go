@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -13,9 +15,15 @@ import (
 )
 
 type aurWarnings struct {
-	Orphans   []string
-	OutOfDate []string
-	Missing   []string
+	Orphans   []string `json:"orphans"`
+	OutOfDate []string `json:"outOfDate"`
+	Missing   []string `json:"missing"`
+	// Stale are the packages only old cached data was available for and
+	// Uncached the ones missing from the cache when offline
+	Stale    []string `json:"stale,omitempty"`
+	Uncached []string `json:"uncached,omitempty"`
+	// Moved are the foreign packages a repo package can take the place of
+	Moved []repoMigration `json:"moved,omitempty"`
 }
 
 // Query is a collection of Results
@@ -77,7 +85,8 @@ func filterPackages() (local []alpm.Package, remote []alpm.Package,
 		found := false
 		// For each DB search for our secret package.
 		_ = dbList.ForEach(func(d alpm.DB) error {
-			if found {
+			// Packages in the local repo were built from the AUR
+			if found || isLocalRepo(d.Name()) {
 				return nil
 			}
 
@@ -100,6 +109,103 @@ func filterPackages() (local []alpm.Package, remote []alpm.Package,
 	return
 }
 
+// searchFields are the fields the AUR can be searched by.
+var searchFields = []string{"name", "name-desc", "maintainer", "depends", "makedepends",
+	"optdepends", "checkdepends", "provides", "conflicts", "replaces", "keywords"}
+
+// searchField returns the field given to --by, name-desc by default.
+func searchField() (string, error) {
+	by, _, exists := cmdArgs.getArg("by")
+	if !exists {
+		return "name-desc", nil
+	}
+
+	for _, field := range searchFields {
+		if by == field {
+			return by, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid search field '%s', expected one of: %s", by, strings.Join(searchFields, ", "))
+}
+
+// rpcSearch searches the AUR for word in the field by.
+func rpcSearch(by, word string) ([]rpc.Pkg, error) {
+	values := url.Values{}
+	values.Set("v", "5")
+	values.Set("type", "search")
+	values.Set("by", by)
+	values.Set("arg", word)
+
+	resp, err := httpGet(rpc.AURURL + values.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Error   string    `json:"error"`
+		Results []rpc.Pkg `json:"results"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+
+	return result.Results, nil
+}
+
+// matchesField reports whether word matches the field by of pkg the way the
+// AUR matches a search: names and descriptions by substring, everything else
+// by its full name.
+func matchesField(pkg *rpc.Pkg, by, word string) bool {
+	word = strings.ToLower(word)
+
+	matchesDep := func(deps []string) bool {
+		for _, dep := range deps {
+			// optdepends carry a description
+			dep = strings.SplitN(dep, ":", 2)[0]
+			if name, _, _ := splitDep(dep); strings.ToLower(name) == word {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch by {
+	case "name":
+		return strings.Contains(strings.ToLower(pkg.Name), word)
+	case "name-desc":
+		return strings.Contains(strings.ToLower(pkg.Name), word) || strings.Contains(strings.ToLower(pkg.Description), word)
+	case "maintainer":
+		return strings.ToLower(pkg.Maintainer) == word
+	case "depends":
+		return matchesDep(pkg.Depends)
+	case "makedepends":
+		return matchesDep(pkg.MakeDepends)
+	case "optdepends":
+		return matchesDep(pkg.OptDepends)
+	case "checkdepends":
+		return matchesDep(pkg.CheckDepends)
+	case "provides":
+		return strings.ToLower(pkg.Name) == word || matchesDep(pkg.Provides)
+	case "conflicts":
+		return matchesDep(pkg.Conflicts)
+	case "replaces":
+		return matchesDep(pkg.Replaces)
+	case "keywords":
+		for _, keyword := range pkg.Keywords {
+			if strings.ToLower(keyword) == word {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // NarrowSearch searches AUR and narrows based on subarguments
 func narrowSearch(pkgS []string, sortS bool) (aurQuery, error) {
 	var r []rpc.Pkg
@@ -110,8 +216,13 @@ func narrowSearch(pkgS []string, sortS bool) (aurQuery, error) {
 		return nil, nil
 	}
 
+	by, err := searchField()
+	if err != nil {
+		return nil, err
+	}
+
 	for i, word := range pkgS {
-		r, err = rpc.Search(word)
+		r, err = aurSearch(by, word)
 		if err == nil {
 			usedIndex = i
 			break
@@ -132,6 +243,13 @@ func narrowSearch(pkgS []string, sortS bool) (aurQuery, error) {
 	var aq aurQuery
 	var n int
 
+	// The other words narrow by name and description unless searching by
+	// name only, a package has a single maintainer and few exact dependencies
+	narrowBy := "name-desc"
+	if by == "name" {
+		narrowBy = "name"
+	}
+
 	for _, res := range r {
 		match := true
 		for i, pkgN := range pkgS {
@@ -139,7 +257,7 @@ func narrowSearch(pkgS []string, sortS bool) (aurQuery, error) {
 				continue
 			}
 
-			if !(strings.Contains(res.Name, pkgN) || strings.Contains(strings.ToLower(res.Description), pkgN)) {
+			if !matchesField(&res, narrowBy, pkgN) {
 				match = false
 				break
 			}
@@ -160,6 +278,9 @@ func narrowSearch(pkgS []string, sortS bool) (aurQuery, error) {
 
 // SyncSearch presents a query to the local repos and to the AUR.
 func syncSearch(pkgS []string) (err error) {
+	out, restore := redirectStdout()
+	defer restore()
+
 	pkgS = removeInvalidTargets(pkgS)
 	var aurErr error
 	var repoErr error
@@ -170,10 +291,17 @@ func syncSearch(pkgS []string) (err error) {
 		aq, aurErr = narrowSearch(pkgS, true)
 	}
 	if mode == modeRepo || mode == modeAny {
-		pq, repoErr = queryRepo(pkgS)
+		pq, repoErr = searchRepo(pkgS)
 		if repoErr != nil {
-			return err
+			return repoErr
+		}
+	}
+
+	if config.Format != "" {
+		if aurErr != nil {
+			fmt.Fprintf(os.Stderr, "Error during AUR search: %s\n", aurErr)
 		}
+		return printFormatted(out, config.Format, searchRecords(pq, aq))
 	}
 
 	switch config.SortMode {
@@ -213,6 +341,10 @@ func syncInfo(pkgS []string) (err error) {
 		return
 	}
 
+	if config.Format != "" {
+		return syncInfoFormatted(aurS, repoS)
+	}
+
 	if len(aurS) != 0 {
 		noDB := make([]string, 0, len(aurS))
 
@@ -258,6 +390,42 @@ func syncInfo(pkgS []string) (err error) {
 }
 
 // Search handles repo searches. Creates a RepoSearch struct.
+// searchRepo searches the sync databases for --by name and name-desc, the
+// other fields are only searched in the AUR.
+func searchRepo(pkgS []string) (repoQuery, error) {
+	by, err := searchField()
+	if err != nil {
+		return nil, err
+	}
+
+	switch by {
+	case "name-desc":
+		return queryRepo(pkgS)
+	case "name":
+		pq, err := queryRepo(pkgS)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := make(repoQuery, 0, len(pq))
+		for _, pkg := range pq {
+			match := true
+			for _, word := range pkgS {
+				if !strings.Contains(strings.ToLower(pkg.Name()), strings.ToLower(word)) {
+					match = false
+					break
+				}
+			}
+			if match {
+				matches = append(matches, pkg)
+			}
+		}
+		return matches, nil
+	}
+
+	return nil, nil
+}
+
 func queryRepo(pkgInputN []string) (s repoQuery, err error) {
 	dbList, err := alpmHandle.SyncDBs()
 	if err != nil {
@@ -469,70 +637,143 @@ func statistics() (info struct {
 }
 
 // Queries the aur for information about specified packages.
+// With --aurmetadata the packages are looked up in the metadata archive
+// instead of the rpc.
+func aurInfo(names []string, warnings *aurWarnings) ([]*rpc.Pkg, error) {
+	var info []*rpc.Pkg
+	var err error
+	seen := make(map[string]int)
+
+	if archive := loadAURArchive(); archive != nil {
+		info = archive.lookup(names)
+	} else if info, err = aurInfoCached(names, warnings); err != nil {
+		return info, err
+	}
+
+	for k, pkg := range info {
+		seen[pkg.Name] = k
+	}
+
+	for _, name := range names {
+		i, ok := seen[name]
+		if !ok && config.Offline {
+			warnings.Uncached = append(warnings.Uncached, name)
+			continue
+		}
+		if !ok {
+			warnings.Missing = append(warnings.Missing, name)
+			continue
+		}
+
+		pkg := info[i]
+
+		if pkg.Maintainer == "" {
+			warnings.Orphans = append(warnings.Orphans, name)
+		}
+		if pkg.OutOfDate != 0 {
+			warnings.OutOfDate = append(warnings.OutOfDate, name)
+		}
+	}
+
+	return info, nil
+}
+
+// aurInfoCached queries the rpc for the packages that are not cached within
+// the TTL. When offline, or when a request to the AUR fails, stale cache
+// entries are used instead for the packages that could not be queried and
+// reported in the warnings.
 // All packages should be queried in a single rpc request except when the number
 // of packages exceeds the number set in config.RequestSplitN.
 // If the number does exceed config.RequestSplitN multiple rpc requests will be
 // performed concurrently.
-func aurInfo(names []string, warnings *aurWarnings) ([]*rpc.Pkg, error) {
-	info := make([]*rpc.Pkg, 0, len(names))
-	seen := make(map[string]int)
+func aurInfoCached(names []string, warnings *aurWarnings) ([]*rpc.Pkg, error) {
+	cache := loadAURCache()
+	now := time.Now()
+	info, stale, uncached := cache.lookup(names, now)
+	query := append(pkgNames(stale), uncached...)
+	var fetched []*rpc.Pkg
 	var mux sync.Mutex
 	var wg sync.WaitGroup
 	var errs MultiError
+	failed := make(stringSet)
 
 	makeRequest := func(n, max int) {
 		defer wg.Done()
-		tempInfo, requestErr := rpc.Info(names[n:max])
+		tempInfo, requestErr := rpc.Info(query[n:max])
 		errs.Add(requestErr)
+		mux.Lock()
+		defer mux.Unlock()
 		if requestErr != nil {
+			for _, name := range query[n:max] {
+				failed.set(name)
+			}
 			return
 		}
-		mux.Lock()
 		for _, _i := range tempInfo {
 			i := _i
-			info = append(info, &i)
+			fetched = append(fetched, &i)
 		}
-		mux.Unlock()
 	}
 
-	for n := 0; n < len(names); n += config.RequestSplitN {
-		max := min(len(names), n+config.RequestSplitN)
-		wg.Add(1)
-		go makeRequest(n, max)
-	}
-
-	wg.Wait()
+	if config.Offline {
+		for _, name := range query {
+			failed.set(name)
+		}
+	} else {
+		for n := 0; n < len(query); n += config.RequestSplitN {
+			max := min(len(query), n+config.RequestSplitN)
+			wg.Add(1)
+			go makeRequest(n, max)
+		}
 
-	if err := errs.Return(); err != nil {
-		return info, err
+		wg.Wait()
 	}
 
-	for k, pkg := range info {
-		seen[pkg.Name] = k
+	// Keep what the requests that went through returned
+	if len(failed) < len(query) {
+		queried := make([]string, 0, len(query))
+		for _, name := range query {
+			if !failed.get(name) {
+				queried = append(queried, name)
+			}
+		}
+
+		info = append(info, fetched...)
+		cache.update(queried, fetched, now)
+		if err := cache.save(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to save AUR cache:", err)
+		}
 	}
 
-	for _, name := range names {
-		i, ok := seen[name]
-		if !ok {
-			warnings.Missing = append(warnings.Missing, name)
-			continue
+	if err := errs.Return(); err != nil {
+		for _, name := range uncached {
+			if failed.get(name) {
+				return info, err
+			}
 		}
 
-		pkg := info[i]
+		fmt.Fprintln(os.Stderr, bold(yellow(arrow+" Warning: "))+"Failed to query the AUR, using cached data:", err)
+	}
 
-		if pkg.Maintainer == "" {
-			warnings.Orphans = append(warnings.Orphans, name)
-		}
-		if pkg.OutOfDate != 0 {
-			warnings.OutOfDate = append(warnings.OutOfDate, name)
+	used := make([]*rpc.Pkg, 0, len(stale))
+	for _, pkg := range stale {
+		if failed.get(pkg.Name) {
+			used = append(used, pkg)
 		}
 	}
+	info = append(info, used...)
+	cache.useStale(used)
+	warnings.Stale = append(warnings.Stale, pkgNames(used)...)
 
 	return info, nil
 }
 
 func aurInfoPrint(names []string) ([]*rpc.Pkg, error) {
-	fmt.Println(bold(cyan("::") + bold(" Querying AUR...")))
+	if config.Offline {
+		fmt.Println(bold(cyan("::") + bold(" Querying AUR cache...")))
+	} else {
+		fmt.Println(bold(cyan("::") + bold(" Querying AUR...")))
+	}
 
 	warnings := &aurWarnings{}
 	info, err := aurInfo(names, warnings)
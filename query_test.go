@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestMatchesField(t *testing.T) {
+	pkg := &rpc.Pkg{
+		Name:         "yay-bin",
+		Description:  "Yet another yogurt",
+		Maintainer:   "Jguer",
+		Depends:      []string{"pacman>=5.1", "sudo"},
+		MakeDepends:  []string{"go"},
+		OptDepends:   []string{"git: build VCS packages"},
+		CheckDepends: []string{"python"},
+		Provides:     []string{"yay=9.2.1"},
+		Conflicts:    []string{"yay"},
+		Keywords:     []string{"AUR", "helper"},
+	}
+
+	tests := []struct {
+		by    string
+		word  string
+		match bool
+	}{
+		{"name", "bin", true},
+		{"name", "yogurt", false},
+		{"name-desc", "YOGURT", true},
+		{"maintainer", "jguer", true},
+		{"maintainer", "jgue", false},
+		{"depends", "pacman", true},
+		{"depends", "pac", false},
+		{"makedepends", "go", true},
+		{"optdepends", "git", true},
+		{"optdepends", "build", false},
+		{"checkdepends", "python", true},
+		{"provides", "yay", true},
+		{"provides", "yay-bin", true},
+		{"conflicts", "yay", true},
+		{"replaces", "yay", false},
+		{"keywords", "aur", true},
+		{"keywords", "help", false},
+	}
+
+	for _, test := range tests {
+		if match := matchesField(pkg, test.by, test.word); match != test.match {
+			t.Errorf("%s %s: expected %t got %t", test.by, test.word, test.match, match)
+		}
+	}
+}
+
+func TestRPCSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("type") != "search" || query.Get("by") != "maintainer" {
+			w.Write([]byte(`{"type": "error", "results": [], "error": "Incorrect by field specified."}`))
+			return
+		}
+		w.Write([]byte(`{"type": "search", "results": [{"Name": "yay", "Maintainer": "` + query.Get("arg") + `"}]}`))
+	}))
+	defer server.Close()
+
+	old := rpc.AURURL
+	rpc.AURURL = server.URL + "/rpc.php?"
+	defer func() { rpc.AURURL = old }()
+
+	pkgs, err := rpcSearch("maintainer", "jguer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Name != "yay" || pkgs[0].Maintainer != "jguer" {
+		t.Errorf("expected [yay] maintained by jguer got %v", pkgs)
+	}
+
+	if _, err = rpcSearch("nonsense", "jguer"); err == nil {
+		t.Errorf("expected an error for an invalid field")
+	}
+}
+
+func TestMaintainedBy(t *testing.T) {
+	pkgs := []*rpc.Pkg{
+		{Name: "yay", Maintainer: "jguer"},
+		{Name: "pikaur", Maintainer: "actionless"},
+		{Name: "yay-bin", Maintainer: "Jguer"},
+		{Name: "orphaned"},
+	}
+
+	maintained := maintainedBy(pkgs, "jguer")
+	if len(maintained) != 2 || maintained[0].Name != "yay" || maintained[1].Name != "yay-bin" {
+		t.Errorf("expected [yay yay-bin] got %v", pkgNames(maintained))
+	}
+}
+
+func TestNarrowSearchBy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type": "search", "results": [
+			{"Name": "foo-git", "Description": "foo from git"},
+			{"Name": "foo", "Description": "foo using git"},
+			{"Name": "git-foo-tools"}
+		]}`))
+	}))
+	defer server.Close()
+
+	old := rpc.AURURL
+	rpc.AURURL = server.URL + "/rpc.php?"
+	defer func() { rpc.AURURL = old }()
+
+	oldConfig, oldArgs := config, cmdArgs
+	config, cmdArgs = defaultSettings(), makeArguments()
+	defer func() { config, cmdArgs = oldConfig, oldArgs }()
+
+	aq, err := narrowSearch([]string{"foo", "git"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aq) != 3 {
+		t.Errorf("expected 3 packages matching by name or description got %d", len(aq))
+	}
+
+	cmdArgs.addParam("by", "name")
+	aq, err = narrowSearch([]string{"foo", "git"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aq) != 2 || aq[0].Name != "foo-git" || aq[1].Name != "git-foo-tools" {
+		t.Errorf("expected [foo-git git-foo-tools] got %v", aq)
+	}
+}
+
+func TestAURInfoCachedPartialFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args := r.URL.Query()["arg[]"]
+		if len(args) == 1 && args[0] == "broken" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		results := make([]string, 0, len(args))
+		for _, name := range args {
+			results = append(results, `{"Name": "`+name+`", "Version": "2"}`)
+		}
+		w.Write([]byte(`{"type": "multiinfo", "results": [` + strings.Join(results, ",") + `]}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "yay-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldURL := rpc.AURURL
+	rpc.AURURL = server.URL + "/rpc.php?"
+	defer func() { rpc.AURURL = oldURL }()
+
+	oldFile := aurCacheFile
+	aurCacheFile = filepath.Join(dir, aurCacheFileName)
+	defer func() { aurCacheFile = oldFile }()
+
+	old := config
+	config = defaultSettings()
+	defer func() { config = old }()
+	config.RequestSplitN = 1
+
+	cache := makeAURCache(time.Minute)
+	cache.update(nil, []*rpc.Pkg{{Name: "good", Version: "1"}, {Name: "broken", Version: "1"}}, time.Unix(0, 0))
+	aurMetadata.once.Do(func() {})
+	oldCache := aurMetadata.cache
+	aurMetadata.cache = cache
+	defer func() { aurMetadata.cache = oldCache }()
+
+	warnings := &aurWarnings{}
+	info, err := aurInfoCached([]string{"good", "broken", "new"}, warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := make([]string, 0, len(info))
+	for _, pkg := range info {
+		versions = append(versions, pkg.Name+"-"+pkg.Version)
+	}
+	sort.Strings(versions)
+	if strings.Join(versions, " ") != "broken-1 good-2 new-2" {
+		t.Errorf("expected [broken-1 good-2 new-2] got %v", versions)
+	}
+	if len(warnings.Stale) != 1 || warnings.Stale[0] != "broken" {
+		t.Errorf("expected broken to be stale got %v", warnings.Stale)
+	}
+
+	fresh, _, _ := cache.lookup([]string{"good", "new"}, time.Now())
+	if len(fresh) != 2 {
+		t.Errorf("expected good and new to be cached got %v", pkgNames(fresh))
+	}
+}
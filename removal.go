@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// removalPkg is an installed package as far as a removal is concerned.
+// Versions are not checked, the installed packages satisfy each other already.
+type removalPkg struct {
+	name     string
+	base     string
+	explicit bool
+	foreign  bool
+	deps     []string
+	provides []string
+}
+
+// satisfies reports whether pkg satisfies a dependency on dep.
+func (pkg *removalPkg) satisfies(dep string) bool {
+	name, _, _ := splitDep(dep)
+	if pkg.name == name {
+		return true
+	}
+
+	for _, provide := range pkg.provides {
+		if provideName, _, _ := splitDep(provide); provideName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// neededBy reports whether by depends on pkg.
+func (pkg *removalPkg) neededBy(by *removalPkg) bool {
+	for _, dep := range by.deps {
+		if pkg.satisfies(dep) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removalReport lists the foreign packages a removal affects.
+type removalReport struct {
+	// Breaks maps the foreign packages left without a dependency to the
+	// dependencies they lose.
+	Breaks map[string][]string
+	// Orphans are the foreign packages only the targets need, -Rs removes
+	// them.
+	Orphans []string
+}
+
+// neededAfter reports whether removing the packages in removed leaves pkg
+// needed by a package that stays, and whether a removed package needed it.
+func neededAfter(pkg *removalPkg, pkgs map[string]*removalPkg, removed stringSet) (bool, bool) {
+	stays := false
+	goes := false
+
+	for _, other := range pkgs {
+		if other.name == pkg.name || !pkg.neededBy(other) {
+			continue
+		}
+
+		if removed.get(other.name) {
+			goes = true
+		} else {
+			stays = true
+		}
+	}
+
+	return stays, goes
+}
+
+// planRemoval works out which foreign packages removing targets from pkgs
+// breaks and which ones it leaves unneeded. Orphans are found the way
+// pacman -Rs does: dependencies of removed packages that were not explicitly
+// installed and that nothing left needs, recursively.
+func planRemoval(pkgs map[string]*removalPkg, targets []string) *removalReport {
+	report := &removalReport{make(map[string][]string), make([]string, 0)}
+	removed := sliceToStringSet(targets)
+
+	for changed := true; changed; {
+		changed = false
+		for _, pkg := range pkgs {
+			if removed.get(pkg.name) || pkg.explicit {
+				continue
+			}
+
+			if stays, goes := neededAfter(pkg, pkgs, removed); goes && !stays {
+				removed.set(pkg.name)
+				changed = true
+				if pkg.foreign {
+					report.Orphans = append(report.Orphans, pkg.name)
+				}
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		if !pkg.foreign || removed.get(pkg.name) {
+			continue
+		}
+
+		for _, dep := range pkg.deps {
+			lost := false
+			kept := false
+			for _, other := range pkgs {
+				if !other.satisfies(dep) {
+					continue
+				}
+				if removed.get(other.name) {
+					lost = true
+				} else {
+					kept = true
+				}
+			}
+
+			if lost && !kept {
+				report.Breaks[pkg.name] = append(report.Breaks[pkg.name], dep)
+			}
+		}
+	}
+
+	sort.Strings(report.Orphans)
+
+	return report
+}
+
+// localRemovalPkgs returns the installed packages.
+func localRemovalPkgs() (map[string]*removalPkg, error) {
+	localDB, err := alpmHandle.LocalDB()
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, _, remoteNames, err := filterPackages()
+	if err != nil {
+		return nil, err
+	}
+	foreign := sliceToStringSet(remoteNames)
+
+	pkgs := make(map[string]*removalPkg)
+	_ = localDB.PkgCache().ForEach(func(pkg alpm.Package) error {
+		base := pkg.Base()
+		if base == "" {
+			base = pkg.Name()
+		}
+
+		pkgs[pkg.Name()] = &removalPkg{
+			pkg.Name(),
+			base,
+			pkg.Reason() == alpm.PkgReasonExplicit,
+			foreign.get(pkg.Name()),
+			dependStrings(pkg.Depends()),
+			dependStrings(pkg.Provides()),
+		}
+		return nil
+	})
+
+	return pkgs, nil
+}
+
+func (report *removalReport) print(recursive bool) {
+	if len(report.Breaks) > 0 {
+		names := make([]string, 0, len(report.Breaks))
+		for name := range report.Breaks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println(bold(yellow(arrow+" Warning: ")) + bold("These foreign packages will break:"))
+		for _, name := range names {
+			fmt.Println("   ", cyan(name), "needs", strings.Join(report.Breaks[name], " "))
+		}
+	}
+
+	if len(report.Orphans) > 0 && !recursive {
+		fmt.Println(bold(cyan("::")), bold("These foreign packages can be removed with -Rs:"))
+		for _, name := range report.Orphans {
+			fmt.Println("   ", cyan(name))
+		}
+	}
+}
+
+// cleanRemovedForeign forgets the VCS info of the foreign packages of before
+// that are no longer installed and offers to delete the build directories of
+// the bases none of their packages are left of.
+func cleanRemovedForeign(before map[string]*removalPkg) error {
+	if err := initAlpmHandle(); err != nil {
+		return err
+	}
+
+	after, err := localRemovalPkgs()
+	if err != nil {
+		return err
+	}
+
+	removed := make([]string, 0)
+	bases := make(stringSet)
+	for _, pkg := range before {
+		if _, ok := after[pkg.name]; !ok && pkg.foreign {
+			removed = append(removed, pkg.name)
+			bases.set(pkg.base)
+		}
+	}
+	for _, pkg := range after {
+		delete(bases, pkg.base)
+	}
+
+	removeVCSPackage(removed)
+
+	dirs := make([]string, 0, len(bases))
+	for _, base := range bases.toSlice() {
+		dir := filepath.Join(config.BuildDir, base)
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	sort.Strings(dirs)
+	fmt.Println()
+	if !continueTask(bold(fmt.Sprintf("Delete the build files of %d removed package base(s)?", len(dirs))), false) {
+		return nil
+	}
+
+	for i, dir := range dirs {
+		fmt.Printf(bold(cyan("::")+" Deleting (%d/%d): %s\n"), i+1, len(dirs), cyan(dir))
+		if err := os.RemoveAll(dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanRemoval(t *testing.T) {
+	pkgs := make(map[string]*removalPkg)
+	add := func(name string, explicit, foreign bool, deps, provides []string) {
+		pkgs[name] = &removalPkg{name, name, explicit, foreign, deps, provides}
+	}
+
+	add("glibc", false, false, nil, nil)
+	add("libfoo-git", false, true, []string{"glibc"}, []string{"libfoo"})
+	add("foo-helper", false, true, []string{"glibc"}, nil)
+	add("foo", true, true, []string{"libfoo>=1", "foo-helper"}, nil)
+	add("bar", true, true, []string{"libfoo"}, nil)
+	add("baz", true, true, []string{"foo-helper", "qux"}, nil)
+	add("qux", true, false, nil, nil)
+	add("qux-alt", false, true, nil, []string{"qux"})
+
+	report := planRemoval(pkgs, []string{"foo", "qux"})
+
+	expectedBreaks := map[string][]string{}
+	if !reflect.DeepEqual(report.Breaks, expectedBreaks) {
+		t.Errorf("expected breaks %v got %v", expectedBreaks, report.Breaks)
+	}
+	if len(report.Orphans) != 0 {
+		t.Errorf("expected no orphans got %v", report.Orphans)
+	}
+
+	report = planRemoval(pkgs, []string{"libfoo-git", "baz"})
+
+	expectedBreaks = map[string][]string{"foo": {"libfoo>=1"}, "bar": {"libfoo"}}
+	if !reflect.DeepEqual(report.Breaks, expectedBreaks) {
+		t.Errorf("expected breaks %v got %v", expectedBreaks, report.Breaks)
+	}
+	if !reflect.DeepEqual(report.Orphans, []string{"qux-alt"}) {
+		t.Errorf("expected orphans [qux-alt] got %v", report.Orphans)
+	}
+
+	report = planRemoval(pkgs, []string{"foo", "baz"})
+
+	if len(report.Breaks) != 0 {
+		t.Errorf("expected no breaks got %v", report.Breaks)
+	}
+	if !reflect.DeepEqual(report.Orphans, []string{"foo-helper", "qux-alt"}) {
+		t.Errorf("expected orphans [foo-helper qux-alt] got %v", report.Orphans)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+
+	alpm "github.com/Jguer/go-alpm"
+)
+
+// repoMigration is a foreign package that a package of the sync databases now
+// takes the place of.
+type repoMigration struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Repository  string `json:"repository"`
+	RepoName    string `json:"repoName"`
+	RepoVersion string `json:"repoVersion"`
+	Reason      string `json:"reason"`
+}
+
+func (m repoMigration) String() string {
+	return fmt.Sprintf("%s (AUR) is now provided by %s/%s", m.Name, m.Repository, m.RepoName)
+}
+
+// migrationReason returns why a repo package can take the place of the
+// installed package name: "replaces" or "provides", or an empty string when it
+// can not. Pacman only removes the installed package in the same transaction
+// when the repo package conflicts with it, so both require a conflict.
+func migrationReason(name, version string, replaces, conflicts, provides []string) string {
+	conflicting := false
+	for _, conflict := range conflicts {
+		if pkgSatisfies(name, version, conflict) {
+			conflicting = true
+			break
+		}
+	}
+	if !conflicting {
+		return ""
+	}
+
+	for _, replace := range replaces {
+		if pkgSatisfies(name, version, replace) {
+			return "replaces"
+		}
+	}
+
+	for _, provide := range provides {
+		if provideSatisfies(provide, name) {
+			return "provides"
+		}
+	}
+
+	return ""
+}
+
+// findRepoMigrations looks through the sync databases for packages that
+// replace or provide the foreign packages in remote. The first database to
+// have one wins, like pacman.
+func findRepoMigrations(remote []alpm.Package) ([]repoMigration, error) {
+	migrations := make([]repoMigration, 0)
+	found := make(stringSet)
+
+	dbList, err := alpmHandle.SyncDBs()
+	if err != nil {
+		return nil, err
+	}
+
+	_ = dbList.ForEach(func(db alpm.DB) error {
+		// Packages in the local repo were built from the AUR
+		if isLocalRepo(db.Name()) {
+			return nil
+		}
+
+		_ = db.PkgCache().ForEach(func(pkg alpm.Package) error {
+			replaces := dependStrings(pkg.Replaces())
+			conflicts := dependStrings(pkg.Conflicts())
+			if len(conflicts) == 0 {
+				return nil
+			}
+			provides := dependStrings(pkg.Provides())
+
+			for _, local := range remote {
+				if found.get(local.Name()) {
+					continue
+				}
+
+				reason := migrationReason(local.Name(), local.Version(), replaces, conflicts, provides)
+				if reason == "" {
+					continue
+				}
+
+				found.set(local.Name())
+				migrations = append(migrations, repoMigration{
+					local.Name(),
+					local.Version(),
+					db.Name(),
+					pkg.Name(),
+					pkg.Version(),
+					reason,
+				})
+			}
+
+			return nil
+		})
+
+		return nil
+	})
+
+	return migrations, nil
+}
+
+// chooseRepoMigrations asks which of the migrations to perform. Nothing is
+// switched unless the user says so, --noconfirm keeps the foreign packages.
+func chooseRepoMigrations(migrations []repoMigration) []repoMigration {
+	chosen := make([]repoMigration, 0, len(migrations))
+
+	for _, m := range migrations {
+		if continueTask(m.String()+" — switch?", false) {
+			chosen = append(chosen, m)
+		}
+	}
+
+	return chosen
+}
+
+// removeMigrated drops the names of the migrated packages from names.
+func removeMigrated(names []string, migrations []repoMigration) []string {
+	migrated := make(stringSet)
+	for _, m := range migrations {
+		migrated.set(m.Name)
+	}
+
+	kept := make([]string, 0, len(names))
+	for _, name := range names {
+		if !migrated.get(name) {
+			kept = append(kept, name)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestMigrationReason(t *testing.T) {
+	type testCase struct {
+		name      string
+		replaces  []string
+		conflicts []string
+		provides  []string
+		reason    string
+	}
+
+	testCases := []testCase{
+		{"foo", []string{"foo"}, []string{"foo"}, nil, "replaces"},
+		{"foo-git", nil, []string{"foo-git"}, []string{"foo-git"}, "provides"},
+		{"foo", []string{"foo"}, nil, []string{"foo"}, ""},
+		{"foo", nil, []string{"foo"}, []string{"bar"}, ""},
+		{"foo", []string{"bar"}, []string{"foo"}, nil, ""},
+		{"foo", []string{"foo-bin"}, []string{"foo", "foo-bin"}, []string{"foo"}, "provides"},
+	}
+
+	for _, tc := range testCases {
+		reason := migrationReason(tc.name, "1.0-1", tc.replaces, tc.conflicts, tc.provides)
+		if reason != tc.reason {
+			t.Errorf("%s: expected reason '%s' got '%s'", tc.name, tc.reason, reason)
+		}
+	}
+}
+
+func TestRemoveMigrated(t *testing.T) {
+	names := []string{"foo", "bar", "baz"}
+	migrations := []repoMigration{{Name: "bar"}, {Name: "qux"}}
+
+	kept := removeMigrated(names, migrations)
+	if len(kept) != 2 || kept[0] != "foo" || kept[1] != "baz" {
+		t.Errorf("expected [foo baz] got %v", kept)
+	}
+}
+
+func TestChooseRepoMigrationsNoConfirm(t *testing.T) {
+	old := config
+	config = defaultSettings()
+	defer func() { config = old }()
+	config.NoConfirm = true
+
+	chosen := chooseRepoMigrations([]repoMigration{{Name: "foo"}})
+	if len(chosen) != 0 {
+		t.Errorf("expected no migration with noconfirm got %v", chosen)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// generateSrcinfo generates the .SRCINFO of pkgbase in dir from its PKGBUILD,
+// the one downloaded from the AUR does not reflect edits made to the PKGBUILD.
+func generateSrcinfo(dir string, pkgbase string) (*gosrc.Srcinfo, error) {
+	stdout, stderr, err := capture(passToMakepkg(filepath.Join(dir, pkgbase), "--printsrcinfo"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate .SRCINFO for %s: %s%s", pkgbase, stderr, err)
+	}
+
+	return gosrc.Parse(stdout)
+}
+
+// diffDeps returns the dependencies of b missing from a and the ones of a
+// missing from b.
+func diffDeps(a, b []string) ([]string, []string) {
+	inA := sliceToStringSet(a)
+	inB := sliceToStringSet(b)
+	added := make([]string, 0)
+	removed := make([]string, 0)
+
+	for _, dep := range b {
+		if !inA.get(dep) {
+			added = append(added, dep)
+		}
+	}
+	for _, dep := range a {
+		if !inB.get(dep) {
+			removed = append(removed, dep)
+		}
+	}
+
+	return added, removed
+}
+
+// updateDepsFromSrcinfo replaces the dependencies of pkg reported by the RPC
+// with the ones from real, printing what differs. It returns the
+// dependencies real adds that are needed to build pkg and whether real
+// dropped any.
+func updateDepsFromSrcinfo(pkg *rpc.Pkg, real *rpc.Pkg) ([]string, bool) {
+	kinds := []string{edgeDepends, edgeMakeDepends, edgeCheckDepends}
+	rpcDeps := [3][]string{pkg.Depends, pkg.MakeDepends, pkg.CheckDepends}
+	realDeps := [3][]string{real.Depends, real.MakeDepends, real.CheckDepends}
+	newDeps := make([]string, 0)
+	dropped := false
+	header := false
+
+	for n := range kinds {
+		added, removed := diffDeps(rpcDeps[n], realDeps[n])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		if !header {
+			fmt.Println(bold(cyan("::")), bold(cyan(pkg.Name)), bold("has different dependencies in its PKGBUILD than on the AUR:"))
+			header = true
+		}
+		for _, dep := range added {
+			fmt.Println("   ", green("+"), kinds[n], dep)
+		}
+		for _, dep := range removed {
+			fmt.Println("   ", red("-"), kinds[n], dep)
+		}
+
+		if kinds[n] != edgeCheckDepends || runsChecks(pkg.PackageBase) {
+			newDeps = append(newDeps, added...)
+		}
+		dropped = dropped || len(removed) > 0
+	}
+
+	pkg.Depends = real.Depends
+	pkg.MakeDepends = real.MakeDepends
+	pkg.CheckDepends = real.CheckDepends
+
+	return newDeps, dropped
+}
+
+// resolveSrcinfoDeps resolves the AUR packages of bases from their .SRCINFO
+// for arch instead of from the RPC. Dependencies the .SRCINFO adds are pulled
+// into the pool. It returns whether any were added or dropped, the install
+// order has to be made again then.
+func (dp *depPool) resolveSrcinfoDeps(bases []Base, srcinfos map[string]*gosrc.Srcinfo, arch string) (bool, error) {
+	newDeps := make(stringSet)
+	dropped := false
+
+	for _, base := range bases {
+		srcinfo, ok := srcinfos[base.Pkgbase()]
+		if !ok {
+			continue
+		}
+
+		real := srcinfoToBase(srcinfo, arch)
+		for _, pkg := range base {
+			for _, realPkg := range real {
+				if realPkg.Name != pkg.Name {
+					continue
+				}
+
+				added, removed := updateDepsFromSrcinfo(pkg, realPkg)
+				for _, dep := range added {
+					newDeps.set(dep)
+				}
+				dropped = dropped || removed
+			}
+		}
+	}
+
+	if len(newDeps) == 0 {
+		return dropped, nil
+	}
+
+	if err := dp.resolveAURDeps(newDeps); err != nil {
+		return true, err
+	}
+
+	return true, dp.solve()
+}
+
+// regenerateSrcinfos replaces the .SRCINFO of the bases in dir that were
+// edited with one generated from their PKGBUILD.
+func regenerateSrcinfos(dir string, srcinfos map[string]*gosrc.Srcinfo, edited []Base) error {
+	for _, base := range edited {
+		srcinfo, err := generateSrcinfo(dir, base.Pkgbase())
+		if err != nil {
+			return err
+		}
+		setBaseChecks(base.Pkgbase(), srcinfo)
+		srcinfos[base.Pkgbase()] = srcinfo
+	}
+
+	return nil
+}
+
+// reviewNewBases runs the bases of do that have no .SRCINFO yet, added to the
+// order after the menus ran, through the diff and edit menus and adds their
+// .SRCINFO to srcinfos. It returns the new bases.
+func reviewNewBases(do *depOrder, srcinfos map[string]*gosrc.Srcinfo, remoteNamesCache stringSet) ([]Base, error) {
+	bases := make([]Base, 0)
+	for _, base := range do.Aur {
+		if _, ok := srcinfos[base.Pkgbase()]; !ok {
+			bases = append(bases, base)
+		}
+	}
+
+	if len(bases) == 0 {
+		return bases, nil
+	}
+
+	newSrcinfos, edited, err := reviewPkgbuilds(bases, pkgbuildsToSkip(bases, make(stringSet)), remoteNamesCache)
+	if err != nil {
+		return bases, err
+	}
+	if err = regenerateSrcinfos(config.BuildDir, newSrcinfos, edited); err != nil {
+		return bases, err
+	}
+	for pkgbase, srcinfo := range newSrcinfos {
+		srcinfos[pkgbase] = srcinfo
+	}
+
+	return bases, nil
+}
+
+// resolveFromSrcinfo resolves the pool again from the .SRCINFO of the bases to
+// build, generated again for the bases that were edited. AUR bases the
+// .SRCINFO files bring in go through the diff and edit menus like any other
+// base before they are resolved in turn. It returns the new install order.
+func resolveFromSrcinfo(dp *depPool, do *depOrder, srcinfos map[string]*gosrc.Srcinfo, edited []Base, remoteNamesCache stringSet) (*depOrder, error) {
+	arch, err := alpmHandle.Arch()
+	if err != nil {
+		return do, err
+	}
+
+	if err = regenerateSrcinfos(config.BuildDir, srcinfos, edited); err != nil {
+		return do, err
+	}
+
+	planned := do.names()
+
+	bases := do.Aur
+	for len(bases) > 0 {
+		changed, err := dp.resolveSrcinfoDeps(bases, srcinfos, arch)
+		if err != nil {
+			return do, err
+		}
+		if !changed {
+			break
+		}
+
+		if err = dp.CheckMissing(); err != nil {
+			return do, err
+		}
+		if do, err = getDepOrder(dp); err != nil {
+			return do, err
+		}
+
+		if bases, err = reviewNewBases(do, srcinfos, remoteNamesCache); err != nil {
+			return do, err
+		}
+	}
+
+	added := make([]string, 0)
+	for _, base := range do.Aur {
+		for _, pkg := range base {
+			if !planned.get(pkg.Name) {
+				added = append(added, "aur/"+pkg.Name)
+			}
+		}
+	}
+	for _, pkg := range do.Repo {
+		if !planned.get(pkg.Name()) {
+			added = append(added, pkg.DB().Name()+"/"+pkg.Name())
+		}
+	}
+
+	if len(added) > 0 {
+		fmt.Println()
+		fmt.Println(bold(cyan("::")), bold("The PKGBUILDs require more packages:"))
+		for _, name := range added {
+			fmt.Println("   ", cyan(name))
+		}
+		fmt.Println()
+		if !continueTask(bold(green("Proceed with install?")), true) {
+			return do, fmt.Errorf("Aborting due to user")
+		}
+	}
+
+	return do, nil
+}
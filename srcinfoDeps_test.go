@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+const testSrcinfo = `pkgbase = foo
+	pkgver = 1.0
+	pkgrel = 1
+	arch = x86_64
+	arch = aarch64
+	makedepends = cmake
+	makedepends_aarch64 = gcc-arm
+	depends = glibc
+	depends_x86_64 = lib32-glibc
+
+pkgname = foo
+`
+
+func TestUpdateDepsFromSrcinfo(t *testing.T) {
+	srcinfo, err := gosrc.Parse(testSrcinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := &rpc.Pkg{
+		Name:        "foo",
+		Depends:     []string{"glibc", "zlib"},
+		MakeDepends: []string{"cmake"},
+	}
+
+	real := srcinfoToBase(srcinfo, "x86_64")
+	added, dropped := updateDepsFromSrcinfo(pkg, real[0])
+
+	if strings.Join(added, " ") != "lib32-glibc" {
+		t.Fatalf("Expected lib32-glibc to be added got %v", added)
+	}
+	if !dropped {
+		t.Fatal("Expected zlib to be reported as dropped")
+	}
+	if strings.Join(pkg.Depends, " ") != "glibc lib32-glibc" || strings.Join(pkg.MakeDepends, " ") != "cmake" {
+		t.Fatalf("Unexpected dependencies: %v %v", pkg.Depends, pkg.MakeDepends)
+	}
+
+	real = srcinfoToBase(srcinfo, "aarch64")
+	added, dropped = updateDepsFromSrcinfo(pkg, real[0])
+
+	if strings.Join(added, " ") != "gcc-arm" {
+		t.Fatalf("Expected gcc-arm to be added got %v", added)
+	}
+	if !dropped {
+		t.Fatal("Expected lib32-glibc to be reported as dropped")
+	}
+}
@@ -165,8 +165,17 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 
 	wg.Wait()
 
+	if mode == modeAny {
+		warnings.Moved, err = findRepoMigrations(remote)
+		errs.Add(err)
+	}
+
 	printLocalNewerThanAUR(remote, aurdata)
 
+	if config.LocalRepo != "" {
+		aurUp = removeBuiltUpgrades(aurUp, repoUp)
+	}
+
 	if develUp != nil {
 		names := make(stringSet)
 		for _, up := range develUp {
@@ -184,6 +193,27 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 	return aurUp, repoUp, errs.Return()
 }
 
+// removeBuiltUpgrades drops AUR upgrades that the local repo already has a
+// build of, those are upgraded from the repo instead.
+func removeBuiltUpgrades(aurUp, repoUp upSlice) upSlice {
+	built := make(map[string]string)
+	for _, up := range repoUp {
+		if isLocalRepo(up.Repository) {
+			built[up.Name] = up.RemoteVersion
+		}
+	}
+
+	filtered := make(upSlice, 0, len(aurUp))
+	for _, up := range aurUp {
+		if version, ok := built[up.Name]; ok && alpm.VerCmp(version, up.RemoteVersion) >= 0 {
+			continue
+		}
+		filtered = append(filtered, up)
+	}
+
+	return filtered
+}
+
 func upDevel(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (toUpgrade upSlice) {
 	toUpdate := make([]alpm.Package, 0)
 	toRemove := make([]string, 0)
@@ -223,6 +253,9 @@ func upDevel(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (toUpgrade upSl
 	for _, pkg := range toUpdate {
 		if pkg.ShouldIgnore() {
 			printIgnoringPackage(pkg, "latest-commit")
+		} else if holds.held(pkg.Name()) {
+			// The version is only known once built so any hold applies
+			printHoldingPackage(pkg, "latest-commit")
 		} else {
 			toUpgrade = append(toUpgrade, upgrade{pkg.Name(), "devel", pkg.Version(), "latest-commit"})
 		}
@@ -247,6 +280,8 @@ func upAUR(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (upSlice, error)
 			(alpm.VerCmp(pkg.Version(), aurPkg.Version) < 0) {
 			if pkg.ShouldIgnore() {
 				printIgnoringPackage(pkg, aurPkg.Version)
+			} else if !holds.allows(pkg.Name(), aurPkg.Version, pkg.Version()) {
+				printHoldingPackage(pkg, aurPkg.Version)
 			} else {
 				toUpgrade = append(toUpgrade, upgrade{aurPkg.Name, "aur", pkg.Version(), aurPkg.Version})
 			}
@@ -333,7 +368,7 @@ func upgradePkgs(aurUp, repoUp upSlice) (stringSet, stringSet, error) {
 		return ignore, aurNames, nil
 	}
 
-	if !config.UpgradeMenu {
+	if !config.UpgradeMenu || config.PrintPlan != "" {
 		for _, pkg := range aurUp {
 			aurNames.set(pkg.Name)
 		}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"sync"
 	"unicode"
 )
@@ -162,3 +163,13 @@ func (err *MultiError) Return() error {
 
 	return nil
 }
+
+// stdoutToStderr sends everything printed to stdout to stderr, for commands
+// whose result has to be alone on stdout. The result is written to the
+// returned writer, the real stdout, until restore is called.
+func stdoutToStderr() (out *os.File, restore func()) {
+	out = os.Stdout
+	os.Stdout = os.Stderr
+
+	return out, func() { os.Stdout = out }
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxWhyPaths caps the paths printed by --why, widely used libraries can be
+// reached in more ways than anyone wants to read.
+const maxWhyPaths = 25
+
+// pathsTo returns up to limit dependency paths from a root of the graph to
+// name, each as the list of edges walked from the root. Paths are searched
+// breadth first so the shortest ones come first. A path ends at the first root
+// found, roots further up are not explored.
+func (g *depGraph) pathsTo(name string, limit int) [][]depEdge {
+	reverse := g.reverse()
+	paths := make([][]depEdge, 0)
+
+	// Only walk up to packages a root can reach, anything else is a dead end
+	roots := make([]string, 0)
+	for _, node := range g.names() {
+		if g.nodes[node].root {
+			roots = append(roots, node)
+		}
+	}
+	reachable := g.reachable(roots)
+
+	// Each path is kept walking up from name, so its last edge leads to the
+	// node it is at
+	onPath := func(path []depEdge, node string) bool {
+		if node == name {
+			return true
+		}
+		for _, edge := range path {
+			if edge.from == node {
+				return true
+			}
+		}
+		return false
+	}
+
+	queue := [][]depEdge{nil}
+	for len(queue) > 0 && len(paths) < limit {
+		path := queue[0]
+		queue = queue[1:]
+
+		node := name
+		if len(path) > 0 {
+			node = path[len(path)-1].from
+		}
+
+		if len(path) > 0 && g.nodes[node].root {
+			found := make([]depEdge, len(path))
+			for i := range path {
+				found[i] = path[len(path)-1-i]
+			}
+			paths = append(paths, found)
+			continue
+		}
+
+		for _, edge := range reverse[node] {
+			if onPath(path, edge.from) || !reachable.get(edge.from) {
+				continue
+			}
+
+			next := make([]depEdge, len(path), len(path)+1)
+			copy(next, path)
+			queue = append(queue, append(next, edge))
+		}
+	}
+
+	return paths
+}
+
+func formatDepPath(path []depEdge) string {
+	var str strings.Builder
+	str.WriteString(cyan(path[0].from))
+
+	for _, edge := range path {
+		label := edge.kind
+		if provided := edge.provided(); provided != "" {
+			label += " " + provided
+		}
+
+		if edge.kind == edgeDepends {
+			str.WriteString(" --" + label + "--> ")
+		} else {
+			str.WriteString(" --" + yellow(label) + "--> ")
+		}
+		str.WriteString(cyan(edge.to))
+	}
+
+	return str.String()
+}
+
+// printWhy prints why name is part of the graph. isRoot and noRoot finish the
+// sentences printed when name is a root and when no root requires it.
+func printWhy(g *depGraph, name string, isRoot string, noRoot string) error {
+	node, ok := g.nodes[name]
+	if !ok {
+		return fmt.Errorf("package '%s' was not found", name)
+	}
+
+	if node.root {
+		fmt.Println(bold(cyan("::")), bold(name), bold(isRoot))
+	}
+
+	paths := g.pathsTo(name, maxWhyPaths+1)
+	if len(paths) == 0 {
+		if !node.root {
+			fmt.Println(bold(cyan("::")), bold(name), bold(noRoot))
+		}
+		return nil
+	}
+
+	fmt.Println(bold(cyan("::")), bold(name), bold("is required by:"))
+	for i, path := range paths {
+		if i == maxWhyPaths {
+			fmt.Println("   ", bold("... more paths not shown"))
+			break
+		}
+		fmt.Println("   ", formatDepPath(path))
+	}
+
+	return nil
+}
+
+// whyInstalled explains why an installed package is installed.
+func whyInstalled(name string) error {
+	g, err := localDepGraph()
+	if err != nil {
+		return err
+	}
+
+	return printWhy(g, name, "is explicitly installed", "is not required by any explicitly installed package")
+}
+
+// whyPlanned explains why a package would be installed by a transaction on
+// targets.
+func whyPlanned(name string, targets []string) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets specified")
+	}
+
+	warnings := &aurWarnings{}
+	dp, err := getDepPool(targets, warnings)
+	if err != nil {
+		return err
+	}
+
+	return printWhy(dp.depGraph(), name, "is a target", "is not required by any target")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func formatPathsPlain(paths [][]depEdge) []string {
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		str := path[0].from
+		for _, edge := range path {
+			str += " " + edge.kind + " " + edge.to
+		}
+		out = append(out, str)
+	}
+
+	return out
+}
+
+func TestPathsTo(t *testing.T) {
+	g := makeDepGraph()
+	g.addNode("app", "1", "local", true)
+	g.addNode("tool", "1", "local", true)
+	g.addNode("lib", "1", "local", false)
+	g.addNode("libc", "1", "local", false)
+	g.addNode("orphan", "1", "local", false)
+	g.addNode("cycle", "1", "local", false)
+
+	g.addEdge("app", "lib", edgeDepends, "lib")
+	g.addEdge("app", "tool", edgeMakeDepends, "tool")
+	g.addEdge("lib", "libc", edgeDepends, "libc.so")
+	g.addEdge("tool", "libc", edgeOptDepends, "libc")
+	g.addEdge("orphan", "libc", edgeDepends, "libc")
+	g.addEdge("libc", "cycle", edgeDepends, "cycle")
+	g.addEdge("cycle", "libc", edgeDepends, "libc")
+
+	paths := formatPathsPlain(g.pathsTo("libc", 10))
+	expected := []string{
+		"tool optdepends libc",
+		"app depends lib depends libc",
+	}
+
+	if strings.Join(paths, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(paths, "\n"))
+	}
+
+	// Roots end a path, app only reaches tool directly
+	paths = formatPathsPlain(g.pathsTo("tool", 10))
+	if len(paths) != 1 || paths[0] != "app makedepends tool" {
+		t.Fatalf("Expected a single path through app, got: %v", paths)
+	}
+
+	if paths := g.pathsTo("orphan", 10); len(paths) != 0 {
+		t.Fatalf("Expected no paths to orphan, got: %v", formatPathsPlain(paths))
+	}
+
+	if paths := g.pathsTo("libc", 1); len(paths) != 1 {
+		t.Fatalf("Expected the limit to be respected, got %d paths", len(paths))
+	}
+
+	// The shortest path is kept even when a longer one is found first
+	g = makeDepGraph()
+	g.addNode("a", "1", "local", true)
+	g.addNode("b", "1", "local", false)
+	g.addNode("c", "1", "local", false)
+	g.addNode("d", "1", "local", true)
+	g.addNode("x", "1", "local", false)
+
+	g.addEdge("c", "x", edgeDepends, "x")
+	g.addEdge("b", "c", edgeDepends, "c")
+	g.addEdge("a", "b", edgeDepends, "b")
+	g.addEdge("d", "x", edgeDepends, "x")
+
+	paths = formatPathsPlain(g.pathsTo("x", 1))
+	if len(paths) != 1 || paths[0] != "d depends x" {
+		t.Fatalf("Expected the path through d, got: %v", paths)
+	}
+}
+
+func TestDepEdgeProvided(t *testing.T) {
+	edges := []depEdge{
+		{"a", "lib", edgeDepends, "lib"},
+		{"a", "lib", edgeDepends, "lib>=1.0"},
+		{"a", "libc", edgeDepends, "libc.so=6-64"},
+		{"a", "jre", edgeOptDepends, "java-runtime"},
+	}
+	expected := []string{"", "", "libc.so", "java-runtime"}
+
+	for n, edge := range edges {
+		if provided := edge.provided(); provided != expected[n] {
+			t.Fatalf("Test %d Failed: Expected %q got %q", n+1, expected[n], provided)
+		}
+	}
+}